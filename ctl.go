@@ -0,0 +1,501 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/btoll/trivial/src/client"
+	"github.com/btoll/trivial/src/server"
+)
+
+// Where the host's commands find the game. It's read from the config
+// file (see `trivial config`), then from the environment and then
+// from the command's flags, each overriding the last.
+//
+// A self-signed certificate from `-generateCert` is trusted by
+// pinning its `Fingerprint`, which the server logs when it starts.
+//
+// The `Actor` is who the server's audit log says made the changes,
+// which defaults to the user's login name.
+//
+// The `HostKey` is needed to watch the game and by the commands that
+// give away the answers or change the scores, since the players have
+// the API key too.
+type hostConfig struct {
+	Host        string `json:"host"`
+	Key         string `json:"key"`
+	HostKey     string `json:"hostKey,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Deck        string `json:"deck,omitempty"`
+	Insecure    bool   `json:"insecure,omitempty"`
+	Actor       string `json:"actor,omitempty"`
+}
+
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "trivial"), nil
+}
+
+func loadConfig() hostConfig {
+	config := hostConfig{Host: "https://127.0.0.1:3000"}
+	if dir, err := configDir(); err == nil {
+		if b, err := os.ReadFile(filepath.Join(dir, "config.json")); err == nil {
+			if err := json.Unmarshal(b, &config); err != nil {
+				log.Fatalf("%s: %v\n", filepath.Join(dir, "config.json"), err)
+			}
+		}
+	}
+	for env, field := range map[string]*string{
+		"TRIVIAL_HOST":        &config.Host,
+		"TRIVIAL_KEY":         &config.Key,
+		"TRIVIAL_HOST_KEY":    &config.HostKey,
+		"TRIVIAL_FINGERPRINT": &config.Fingerprint,
+		"TRIVIAL_DECK":        &config.Deck,
+		"TRIVIAL_ACTOR":       &config.Actor,
+	} {
+		if v := os.Getenv(env); v != "" {
+			*field = v
+		}
+	}
+	return config
+}
+
+func (c hostConfig) save() error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(c, "", "    ")
+	if err != nil {
+		return err
+	}
+	// The file holds the API key and the host key.
+	return os.WriteFile(filepath.Join(dir, "config.json"), b, 0600)
+}
+
+// Registers the flags that every host command shares, which default
+// to the config.
+func (c *hostConfig) flags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Host, "host", c.Host, "URL of game host server")
+	fs.StringVar(&c.Key, "key", c.Key, "API key of the game")
+	fs.StringVar(&c.HostKey, "hostKey", c.HostKey, "Host key of the game, which watching the game, its report and changing the scores need")
+	fs.StringVar(&c.Fingerprint, "fingerprint", c.Fingerprint, "SHA-256 fingerprint of the server's (self-signed) TLS certificate")
+	fs.BoolVar(&c.Insecure, "insecure", c.Insecure, "Don't verify the server's (self-signed) TLS certificate")
+	fs.StringVar(&c.Actor, "actor", c.Actor, "Who the server's audit log records as making the changes (defaults to your login name)")
+}
+
+func normalizeFingerprint(s string) string {
+	s = strings.TrimPrefix(strings.ToUpper(s), "SHA256:")
+	return strings.ReplaceAll(s, ":", "")
+}
+
+// A pinned certificate is trusted whoever signed it, as long as its
+// fingerprint matches.
+func (c hostConfig) tlsConfig() *tls.Config {
+	if c.Fingerprint == "" {
+		return &tls.Config{InsecureSkipVerify: c.Insecure}
+	}
+	return &tls.Config{
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("the server didn't send a certificate")
+			}
+			got := server.Fingerprint(rawCerts[0])
+			if normalizeFingerprint(got) != normalizeFingerprint(c.Fingerprint) {
+				return fmt.Errorf("the server's certificate fingerprint `%s` doesn't match the pinned fingerprint", got)
+			}
+			return nil
+		},
+	}
+}
+
+func (c hostConfig) httpClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: c.tlsConfig()},
+	}
+}
+
+func (c hostConfig) client() *client.Client {
+	if c.Key == "" {
+		log.Fatalln("the API key is required (see `trivial config`)")
+	}
+	api := client.New(c.Host, c.Key, c.httpClient())
+	api.HostKey = c.HostKey
+	api.Actor = c.actor()
+	return api
+}
+
+func (c hostConfig) actor() string {
+	if c.Actor != "" {
+		return c.Actor
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
+// Parses the flags of a host command, which needs at least `min`
+// arguments.
+func parseHostFlags(fs *flag.FlagSet, args []string, min int, usage string) (hostConfig, []string) {
+	config := loadConfig()
+	config.flags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: trivial %s [flags] %s\n", fs.Name(), usage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < min {
+		fs.Usage()
+		os.Exit(2)
+	}
+	return config, fs.Args()
+}
+
+// Saves where the game is, so the other commands don't need to be
+// told. With `-pin`, the server's certificate is fetched and its
+// fingerprint is pinned, which should be checked against the one
+// the server logged when it started.
+func configure(args []string) {
+	fs := flag.NewFlagSet("config", flag.ExitOnError)
+	pin := fs.Bool("pin", false, "Pin the fingerprint of the server's current certificate")
+	deck := fs.String("deck", "", "File of questions that `trivial next` asks from")
+	config, _ := parseHostFlags(fs, args, 0, "")
+	if *deck != "" {
+		config.Deck = *deck
+	}
+	if *pin {
+		conn, err := tls.Dial("tcp", hostAddress(config.Host), &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			log.Fatalln(err)
+		}
+		certs := conn.ConnectionState().PeerCertificates
+		conn.Close()
+		if len(certs) == 0 {
+			log.Fatalln("the server didn't send a certificate")
+		}
+		config.Fingerprint = server.Fingerprint(certs[0].Raw)
+		fmt.Printf("pinned certificate fingerprint `%s`\n", config.Fingerprint)
+	}
+	if err := config.save(); err != nil {
+		log.Fatalln(err)
+	}
+	dir, _ := configDir()
+	fmt.Printf("saved the config to `%s`\n", filepath.Join(dir, "config.json"))
+}
+
+func hostAddress(host string) string {
+	u, err := url.Parse(host)
+	if err != nil {
+		log.Fatalln("server url could not be parsed")
+	}
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return u.Host
+}
+
+// Asks a question in the format of a deck's line. See [server.ParseQuestionRequest].
+func ask(args []string) {
+	fs := flag.NewFlagSet("ask", flag.ExitOnError)
+	config, args := parseHostFlags(fs, args, 1, "'Question|weight|answers|choice|choice|...'")
+	asked, err := askLine(config.client(), strings.Join(args, " "))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(asked)
+}
+
+// Returns what was asked.
+func askLine(c *client.Client, line string) (string, error) {
+	req, err := server.ParseQuestionRequest(line)
+	if err != nil {
+		return "", err
+	}
+	q, err := c.Ask(context.Background(), req)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("asked question %d: %s", q.Number, q.Question), nil
+}
+
+// Where `trivial next` is in the deck, which starts over for a new
+// game (that is, a new API key) or a different deck.
+type deckPosition struct {
+	Key  string `json:"key"`
+	Deck string `json:"deck"`
+	Line int    `json:"line"`
+}
+
+func positionPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "next.json"), nil
+}
+
+func loadPosition(key, deck string) deckPosition {
+	position := deckPosition{Key: key, Deck: deck}
+	path, err := positionPath()
+	if err != nil {
+		return position
+	}
+	var saved deckPosition
+	if b, err := os.ReadFile(path); err == nil && json.Unmarshal(b, &saved) == nil {
+		if saved.Key == key && saved.Deck == deck {
+			return saved
+		}
+	}
+	return position
+}
+
+func (p deckPosition) save() error {
+	path, err := positionPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
+
+// The questions of a deck, one per line. Blank lines are skipped.
+func readDeck(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// Asks the next question of the deck, which replaces the `awk 'NR=='$i”`
+// trick.
+func next(args []string) {
+	fs := flag.NewFlagSet("next", flag.ExitOnError)
+	deck := fs.String("deck", "", "File of questions, one per line (defaults to the config)")
+	line := fs.Int("n", 0, "Ask the question on this line instead, and carry on from there")
+	config, _ := parseHostFlags(fs, args, 0, "")
+	if *deck != "" {
+		config.Deck = *deck
+	}
+	asked, err := askNext(config, *line)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(asked)
+}
+
+// Returns what was asked.
+func askNext(config hostConfig, line int) (string, error) {
+	if config.Deck == "" {
+		return "", errors.New("a deck is required (see `trivial config -deck`)")
+	}
+	deck, err := filepath.Abs(config.Deck)
+	if err != nil {
+		return "", err
+	}
+	lines, err := readDeck(deck)
+	if err != nil {
+		return "", err
+	}
+	position := loadPosition(config.Key, deck)
+	if line > 0 {
+		position.Line = line - 1
+	}
+	if position.Line >= len(lines) {
+		return "", fmt.Errorf("all %d questions of the deck have been asked", len(lines))
+	}
+	req, err := server.ParseQuestionRequest(lines[position.Line])
+	if err != nil {
+		return "", fmt.Errorf("line %d: %v", position.Line+1, err)
+	}
+	q, err := config.client().Ask(context.Background(), req)
+	if err != nil {
+		return "", err
+	}
+	position.Line++
+	return fmt.Sprintf("asked question %d (%d of %d): %s", q.Number, position.Line, len(lines), q.Question), position.save()
+}
+
+func kick(args []string) {
+	fs := flag.NewFlagSet("kick", flag.ExitOnError)
+	team := fs.Bool("team", false, "Kick every player of the team")
+	config, args := parseHostFlags(fs, args, 1, "name")
+	c := config.client()
+	var err error
+	if *team {
+		err = c.KickTeam(context.Background(), args[0])
+	} else {
+		err = c.Kick(context.Background(), args[0])
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func msg(args []string) {
+	fs := flag.NewFlagSet("msg", flag.ExitOnError)
+	config, args := parseHostFlags(fs, args, 2, "name message")
+	if err := config.client().Message(context.Background(), args[0], strings.Join(args[1:], " ")); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func notify(args []string) {
+	fs := flag.NewFlagSet("notify", flag.ExitOnError)
+	config, args := parseHostFlags(fs, args, 1, "message")
+	if err := config.client().Notify(context.Background(), strings.Join(args, " ")); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// Adds points (which can be negative) to a score, or sets it.
+func score(args []string) {
+	fs := flag.NewFlagSet("score", flag.ExitOnError)
+	team := fs.Bool("team", false, "Change a team's score")
+	set := fs.Bool("set", false, "Set the score instead of adding to it")
+	config, args := parseHostFlags(fs, args, 2, "name points")
+	points, err := strconv.Atoi(args[1])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	c := config.client()
+	ctx := context.Background()
+	var res *server.ScoreResponse
+	switch {
+	case *team && *set:
+		res, err = c.SetTeamScore(ctx, args[0], points)
+	case *team:
+		res, err = c.AddTeamPoints(ctx, args[0], points)
+	case *set:
+		res, err = c.SetScore(ctx, args[0], points)
+	default:
+		res, err = c.AddPoints(ctx, args[0], points)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("%s has %d points\n", res.Name, res.Score)
+}
+
+func reset(args []string) {
+	fs := flag.NewFlagSet("reset", flag.ExitOnError)
+	config, _ := parseHostFlags(fs, args, 0, "")
+	if err := config.client().ResetScores(context.Background()); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func undo(args []string) {
+	fs := flag.NewFlagSet("undo", flag.ExitOnError)
+	config, args := parseHostFlags(fs, args, 0, "[n]")
+	n := 1
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	res, err := config.client().Undo(context.Background(), n)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("undid %d score changes\n", len(res.Undone))
+	printScores(res.Scores)
+}
+
+func restore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	config, args := parseHostFlags(fs, args, 1, "question")
+	question, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	res, err := config.client().Restore(context.Background(), question)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	printScores(res.Scores)
+}
+
+func printScores(scores server.AuditScores) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+	for _, name := range sortedKeys(scores.Teams) {
+		fmt.Fprintf(w, "team %s\t%d\n", name, scores.Teams[name])
+	}
+	for _, name := range sortedKeys(scores.Players) {
+		fmt.Fprintf(w, "%s\t%d\n", name, scores.Players[name])
+	}
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func scoreboard(args []string) {
+	fs := flag.NewFlagSet("scoreboard", flag.ExitOnError)
+	config, _ := parseHostFlags(fs, args, 0, "")
+	c := config.client()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+	scoreboard, teams, err := c.Standings(context.Background())
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if teams != nil {
+		for i, team := range teams {
+			fmt.Fprintf(w, "%d.\t%s\t%d\n", i+1, team.Name, team.Score)
+			for _, player := range team.Players {
+				fmt.Fprintf(w, "\t  %s\t%d\n", player.Name, player.Score)
+			}
+		}
+		return
+	}
+	for _, player := range scoreboard {
+		fmt.Fprintf(w, "%d.\t%s\t%d\n", player.Rank, player.Name, player.Score)
+	}
+}
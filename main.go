@@ -5,20 +5,56 @@ import (
 	"fmt"
 	"log"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/btoll/trivial/src/profile"
 	"github.com/btoll/trivial/src/server"
+	"github.com/btoll/trivial/src/webhook"
 )
 
 var (
 	wssURL          = flag.String("wss", "wss://127.0.0.1:3000", "URL of game websocket server")
 	hostURL         = flag.String("host", "https://127.0.0.1:3000", "URL of game host server")
 	gameName        = flag.String("game", "default", "Name of game")
+	deck            = flag.String("deck", "", "Directory of the media files referenced by questions")
 	generateCert    = flag.Bool("generateCert", false, "Generate a new TLS certificate")
 	tokenExpiration = flag.Float64("tokenExpiration", 3600, "Token expiration (in seconds)")
+	answerPolicy    = flag.String("answerPolicy", server.AnswerOnce, "Whether players answer once or can change their answer until the deadline (once, change)")
+	teams           = flag.String("teams", "", "Comma-separated names of the teams that players are assigned to")
+	teamPolicy      = flag.String("teamPolicy", "", "Play in teams, where a team's answer is decided by its captain, majority or first (captain, majority, first)")
+	timeLimit       = flag.Int("timeLimit", 0, "Time limit to answer a question (in seconds), 0 for no limit")
+	tieBreakers     = flag.String("tieBreakers", "", "Comma-separated tie-breakers for players with the same score, in order (correct, time, last)")
+	suddenDeath     = flag.String("suddenDeath", "", "Numeric question played by the players tied for first when the game is finished, e.g. \"In what year was Woodstock?|1|number:1969\"")
+	buzzerDelay     = flag.Duration("buzzerDelay", server.DefaultBuzzerDelay, "How long after a question is shown that the buzzers of a buzzer round are armed")
+	buzzerLockout   = flag.Duration("buzzerLockout", server.DefaultBuzzerLockout, "How long a player that buzzes before the buzzers are armed is locked out")
+	buzzerWindow    = flag.Duration("buzzerWindow", server.DefaultBuzzerWindow, "How long the player that buzzed in has to answer")
+	idleTimeout     = flag.Duration("idleTimeout", server.DefaultIdleTimeout, "How long a player that guesses over HTTP can go without guessing or following the feed before they're benched")
+	profiles        = flag.String("profiles", "", "File that keeps the players' profiles across games, which aren't kept if empty")
+	webhooks        = flag.String("webhooks", "", "JSON file of the webhooks that the game's events are sent to")
+	journal         = flag.String("journal", "", "File that every change to the game is recorded in, from which the game is recovered if it already has events")
 )
 
+// The host's commands, e.g. `trivial next`, which are otherwise
+// flags of the server.
+var commands = map[string]func([]string){
+	"ask":        ask,
+	"config":     configure,
+	"kick":       kick,
+	"msg":        msg,
+	"next":       next,
+	"notify":     notify,
+	"report":     report,
+	"reset":      reset,
+	"restore":    restore,
+	"score":      score,
+	"scoreboard": scoreboard,
+	"undo":       undo,
+	"watch":      watch,
+}
+
 func parseURL(s string) server.Socket {
 	parsedUrl, err := url.Parse(s)
 	if err != nil {
@@ -40,6 +76,13 @@ func bound(n int) string {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			command(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Parse()
 
 	wssSock := parseURL(*wssURL)
@@ -50,10 +93,29 @@ func main() {
 	}
 
 	sockserv := server.NewSocketServer(socketServer)
+	sockserv.Deck = *deck
 	fmt.Printf("%s\ncreated new websocket server `%s`\n",
 		bound(75),
 		socketServer)
 
+	if *profiles != "" {
+		store, err := profile.Open(*profiles)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		sockserv.Profiles = store
+		fmt.Printf("keeping %d player profiles in `%s`\n", len(store.Profiles), *profiles)
+	}
+
+	if *webhooks != "" {
+		hooks, err := webhook.Load(*webhooks)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		sockserv.Webhooks = webhook.New(hooks, nil)
+		fmt.Printf("sending the game's events to %d webhooks\n", len(hooks))
+	}
+
 	if *generateCert {
 		server.GenerateCert(server.TLSCert{
 			EcdsaCurve: "P384",
@@ -64,11 +126,73 @@ func main() {
 		fmt.Printf("generated new TLS certificate for domains `%s` and `%s`\n", "127.0.0.1", hostSock.Domain)
 	}
 
+	if *answerPolicy != server.AnswerOnce && *answerPolicy != server.AnswerChange {
+		log.Fatalf("answer policy must be either `%s` or `%s`\n", server.AnswerOnce, server.AnswerChange)
+	}
+
 	game := server.NewGame(*gameName, *tokenExpiration)
-	fmt.Printf("registered game `%s` with key `%s` on host `%s`\n%s\n",
+	game.AnswerPolicy = *answerPolicy
+	game.TimeLimit = time.Duration(*timeLimit) * time.Second
+	var err error
+	game.TieBreakers, err = server.ParseTieBreakers(*tieBreakers)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if *suddenDeath != "" {
+		game.SuddenDeath, err = server.ParseSuddenDeath(*suddenDeath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	game.BuzzerDelay = *buzzerDelay
+	game.BuzzerLockout = *buzzerLockout
+	game.BuzzerWindow = *buzzerWindow
+	game.IdleTimeout = *idleTimeout
+	switch *teamPolicy {
+	case "":
+		if *teams != "" {
+			log.Fatalln("the teams need a team policy")
+		}
+	case server.TeamCaptain, server.TeamMajority, server.TeamFirst:
+		game.TeamPolicy = *teamPolicy
+		for _, name := range strings.Split(*teams, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				game.Teams = append(game.Teams, &server.Team{Name: name})
+			}
+		}
+	default:
+		log.Fatalf("team policy must be one of `%s`, `%s` or `%s`\n", server.TeamCaptain, server.TeamMajority, server.TeamFirst)
+	}
+	if *journal != "" {
+		j, events, err := server.OpenJournal(*journal)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		// A game that is recovered keeps the settings (and keys) that
+		// it was created with, whatever the flags are now.
+		if len(events) > 0 {
+			game, err = server.ReplayGame(events)
+			if err != nil {
+				log.Fatalf("%s: %v\n", *journal, err)
+			}
+			fmt.Printf("recovered game `%s` from %d events in `%s`\n", game.Name, len(events), *journal)
+		}
+		game.Journal = j
+		if err := sockserv.Recover(game); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	fmt.Printf("registered game `%s` with key `%s` on host `%s`\ndisplay the game at `%s/display?token=%s`\nhost key `%s` (don't share it with the players)\n%s\n",
 		game.Name,
 		game.Key.Key,
 		hostSock,
+		hostSock,
+		game.DisplayKey,
+		game.HostKey,
 		bound(75))
+	// The host's commands can pin a self-signed certificate.
+	if fingerprint, err := server.CertFingerprint("cert.pem"); err == nil {
+		fmt.Printf("certificate fingerprint `%s`\n", fingerprint)
+	}
 	sockserv.RegisterAndStartGame(game)
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/btoll/trivial/src/server"
+)
+
+// Downloads the post-game report of a running game, for example:
+//
+//	$ trivial report -key bZu5SaAQ5d3EEwz1bkEp -hostKey 7d1e90a2... -format html -o report.html
+func report(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	format := fs.String("format", "json", "Format of the report (json, csv, html)")
+	output := fs.String("o", "", "File to write the report to (defaults to stdout)")
+	config, _ := parseHostFlags(fs, args, 0, "")
+
+	if config.Key == "" {
+		log.Fatalln("the API key is required (see `trivial config`)")
+	}
+	if config.HostKey == "" {
+		log.Fatalln("the host key is required (see `trivial config`)")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/report?format=%s", config.Host, url.QueryEscape(*format)), nil)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	req.Header.Set("X-TRIVIA-APIKEY", config.Key)
+	req.Header.Set(server.HostKeyHeader, config.HostKey)
+	res, err := config.httpClient().Do(req)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(res.Body)
+		log.Fatalf("%s: %s", res.Status, b)
+	}
+
+	w := os.Stdout
+	if *output != "" {
+		w, err = os.Create(*output)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer w.Close()
+	}
+	if _, err := io.Copy(w, res.Body); err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -0,0 +1,283 @@
+// A client of the game's JSON API, which is described by the OpenAPI
+// document that the server serves at `/openapi.json`. For example:
+//
+//	c := client.New("https://127.0.0.1:3000", "bZu5SaAQ5d3EEwz1bkEp", nil)
+//	if _, err := c.Start(ctx); err != nil {
+//		log.Fatalln(err)
+//	}
+//	_, err := c.Ask(ctx, server.QuestionRequest{
+//		Question: "In what year was Woodstock?",
+//		Weight:   50,
+//		Kind:     server.KindNumber,
+//		Number:   &year,
+//	})
+//
+// An error reported by the server is a [*server.StatusError], which
+// has the status of the response.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/btoll/trivial/src/profile"
+	"github.com/btoll/trivial/src/server"
+)
+
+type Client struct {
+	// The URL of the game's host server, e.g. `https://127.0.0.1:3000`.
+	Host string
+	// The API key of the game.
+	Key string
+	// The host key of the game, which the endpoints that give away
+	// the answers need. See [server.HostKeyHeader].
+	HostKey string
+	// Who is making the requests, which the server records in its
+	// audit log. See [server.ActorHeader].
+	Actor      string
+	HTTPClient *http.Client
+}
+
+// Uses the default HTTP client when `httpClient` is nil.
+func New(host, key string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		Host:       strings.TrimSuffix(host, "/"),
+		Key:        key,
+		HTTPClient: httpClient,
+	}
+}
+
+// Sends `in` (if not nil) as the JSON body of the request and decodes
+// the response into `out` (if not nil).
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.Host+server.APIPrefix+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-TRIVIA-APIKEY", c.Key)
+	if c.HostKey != "" {
+		req.Header.Set(server.HostKeyHeader, c.HostKey)
+	}
+	if c.Actor != "" {
+		req.Header.Set(server.ActorHeader, c.Actor)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusBadRequest {
+		var e server.ErrorResponse
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Error == nil {
+			return &server.StatusError{Status: res.StatusCode, Message: res.Status}
+		}
+		return e.Error
+	}
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// Like [Client.do], but returns the decoded response, which is nil
+// when there is an error.
+func call[T any](ctx context.Context, c *Client, method, path string, in any) (*T, error) {
+	var out T
+	if err := c.do(ctx, method, path, in, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func escape(name string) string {
+	return url.PathEscape(name)
+}
+
+func (c *Client) State(ctx context.Context) (*server.GameState, error) {
+	return call[server.GameState](ctx, c, http.MethodGet, "/state", nil)
+}
+
+func (c *Client) SetState(ctx context.Context, state string) (*server.GameState, error) {
+	return call[server.GameState](ctx, c, http.MethodPut, "/state", server.StateRequest{State: state})
+}
+
+func (c *Client) Start(ctx context.Context) (*server.GameState, error) {
+	return c.SetState(ctx, server.StateRunning)
+}
+
+func (c *Client) Pause(ctx context.Context) (*server.GameState, error) {
+	return c.SetState(ctx, server.StatePaused)
+}
+
+func (c *Client) Resume(ctx context.Context) (*server.GameState, error) {
+	return c.SetState(ctx, server.StateRunning)
+}
+
+func (c *Client) Finish(ctx context.Context) (*server.GameState, error) {
+	return c.SetState(ctx, server.StateFinished)
+}
+
+// Asks a question and returns it as it was asked.
+func (c *Client) Ask(ctx context.Context, question server.QuestionRequest) (*server.CurrentQuestion, error) {
+	return call[server.CurrentQuestion](ctx, c, http.MethodPost, "/questions", question)
+}
+
+// Ends the wagering of a wager question and shows the question.
+func (c *Client) Show(ctx context.Context) (*server.CurrentQuestion, error) {
+	return call[server.CurrentQuestion](ctx, c, http.MethodPost, "/questions/current/show", nil)
+}
+
+// Closes the current question and returns its statistics.
+func (c *Client) Reveal(ctx context.Context) (*server.QuestionStats, error) {
+	return call[server.QuestionStats](ctx, c, http.MethodPost, "/questions/current/reveal", nil)
+}
+
+func (c *Client) QuestionStats(ctx context.Context, n int) (*server.QuestionStats, error) {
+	return call[server.QuestionStats](ctx, c, http.MethodGet, "/questions/"+strconv.Itoa(n)+"/stats", nil)
+}
+
+func (c *Client) BeginRound(ctx context.Context, round server.RoundRequest) (*server.Round, error) {
+	return call[server.Round](ctx, c, http.MethodPost, "/rounds", round)
+}
+
+func (c *Client) Players(ctx context.Context) (server.GamePlayers, error) {
+	var players server.GamePlayers
+	err := c.do(ctx, http.MethodGet, "/players", nil, &players)
+	return players, err
+}
+
+func (c *Client) Kick(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/players/"+escape(name), nil, nil)
+}
+
+func (c *Client) KickTeam(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/teams/"+escape(name), nil, nil)
+}
+
+// Adds the points (which can be negative) to a player's score.
+func (c *Client) AddPoints(ctx context.Context, name string, points int) (*server.ScoreResponse, error) {
+	return call[server.ScoreResponse](ctx, c, http.MethodPatch, "/players/"+escape(name)+"/score", server.ScoreRequest{Points: &points})
+}
+
+func (c *Client) SetScore(ctx context.Context, name string, score int) (*server.ScoreResponse, error) {
+	return call[server.ScoreResponse](ctx, c, http.MethodPatch, "/players/"+escape(name)+"/score", server.ScoreRequest{Score: &score})
+}
+
+// Adds the points (which can be negative) to a team's score.
+func (c *Client) AddTeamPoints(ctx context.Context, name string, points int) (*server.ScoreResponse, error) {
+	return call[server.ScoreResponse](ctx, c, http.MethodPatch, "/teams/"+escape(name)+"/score", server.ScoreRequest{Points: &points})
+}
+
+func (c *Client) SetTeamScore(ctx context.Context, name string, score int) (*server.ScoreResponse, error) {
+	return call[server.ScoreResponse](ctx, c, http.MethodPatch, "/teams/"+escape(name)+"/score", server.ScoreRequest{Score: &score})
+}
+
+func (c *Client) Message(ctx context.Context, name, message string) error {
+	return c.do(ctx, http.MethodPost, "/players/"+escape(name)+"/messages", server.MessageRequest{Message: message}, nil)
+}
+
+func (c *Client) Notify(ctx context.Context, message string) error {
+	return c.do(ctx, http.MethodPost, "/notifications", server.MessageRequest{Message: message}, nil)
+}
+
+func (c *Client) ResetScores(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/scores", nil, nil)
+}
+
+// Undoes the host's last `n` score changes.
+func (c *Client) Undo(ctx context.Context, n int) (*server.ScoresResponse, error) {
+	return call[server.ScoresResponse](ctx, c, http.MethodPost, "/scores/undo", server.UndoRequest{Count: n})
+}
+
+// Restores the scores to what they were before a question was asked.
+func (c *Client) Restore(ctx context.Context, question int) (*server.ScoresResponse, error) {
+	return call[server.ScoresResponse](ctx, c, http.MethodPost, "/scores/restore", server.RestoreRequest{Question: question})
+}
+
+// The ranked players. Use [Client.TeamScoreboard] in teams mode.
+func (c *Client) Scoreboard(ctx context.Context) (server.Scoreboard, error) {
+	var scoreboard server.Scoreboard
+	err := c.do(ctx, http.MethodGet, "/scoreboard", nil, &scoreboard)
+	return scoreboard, err
+}
+
+func (c *Client) TeamScoreboard(ctx context.Context) (server.TeamScoreboard, error) {
+	var scoreboard server.TeamScoreboard
+	err := c.do(ctx, http.MethodGet, "/scoreboard", nil, &scoreboard)
+	return scoreboard, err
+}
+
+// Either the ranked players or, in teams mode, the ranked teams,
+// since the scoreboard of a team lists its players.
+func (c *Client) Standings(ctx context.Context) (server.Scoreboard, server.TeamScoreboard, error) {
+	var b json.RawMessage
+	if err := c.do(ctx, http.MethodGet, "/scoreboard", nil, &b); err != nil {
+		return nil, nil, err
+	}
+	var teams []struct {
+		Players json.RawMessage `json:"players"`
+	}
+	if err := json.Unmarshal(b, &teams); err != nil {
+		return nil, nil, err
+	}
+	if len(teams) > 0 && teams[0].Players != nil {
+		var scoreboard server.TeamScoreboard
+		err := json.Unmarshal(b, &scoreboard)
+		return nil, scoreboard, err
+	}
+	var scoreboard server.Scoreboard
+	err := json.Unmarshal(b, &scoreboard)
+	return scoreboard, nil, err
+}
+
+func (c *Client) History(ctx context.Context) (*server.Timeline, error) {
+	return call[server.Timeline](ctx, c, http.MethodGet, "/scoreboard/history", nil)
+}
+
+func (c *Client) Season(ctx context.Context) ([]*profile.Standing, error) {
+	var standings []*profile.Standing
+	err := c.do(ctx, http.MethodGet, "/season", nil, &standings)
+	return standings, err
+}
+
+func (c *Client) Report(ctx context.Context) (*server.Report, error) {
+	return call[server.Report](ctx, c, http.MethodGet, "/report", nil)
+}
+
+// Fetches the OpenAPI document that describes the server.
+func (c *Client) OpenAPI(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.Host+"/openapi.json", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
@@ -0,0 +1,290 @@
+// Package metrics keeps the server's metrics in-process and serves
+// them in the Prometheus text format, so a running game can be
+// scraped without an external service. For example:
+//
+//	var requests = metrics.NewCounter("trivial_requests_total", "Requests by path.", "path")
+//
+//	requests.Inc(r.URL.Path)
+//
+// The metrics are registered with the [Default] registry when they're
+// created, and a metric registered again with the same name replaces
+// the first.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The buckets (in seconds) of a histogram that doesn't give its own.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+var Default = &Registry{}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, registered := range r.collectors {
+		if registered.name() == c.name() {
+			r.collectors[i] = c
+			return
+		}
+	}
+	r.collectors = append(r.collectors, c)
+	sort.Slice(r.collectors, func(i, j int) bool {
+		return r.collectors[i].name() < r.collectors[j].name()
+	})
+}
+
+// Writes every metric in the Prometheus text format.
+func (r *Registry) WriteText(w io.Writer) {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteText(w)
+}
+
+// Serves the metrics of the [Default] registry.
+func Handler() http.Handler {
+	return Default
+}
+
+// The name, help and label names that every kind of metric has.
+type desc struct {
+	Name   string
+	Help   string
+	Labels []string
+}
+
+func (d desc) name() string {
+	return d.Name
+}
+
+func (d desc) header(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.Name, escapeHelp(d.Help), d.Name, kind)
+}
+
+// A metric is told its label values in the order of its label names.
+func (d desc) key(values []string) string {
+	if len(values) != len(d.Labels) {
+		panic(fmt.Sprintf("metric `%s` has %d labels but was given %d values", d.Name, len(d.Labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// Formats the labels, along with an extra one (like a histogram's
+// `le`) when it's given.
+func (d desc) labels(values []string, extra ...string) string {
+	pairs := make([]string, 0, len(values)+1)
+	for i, value := range values {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", d.Labels[i], escapeLabel(value)))
+	}
+	if len(extra) == 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", extra[0], escapeLabel(extra[1])))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type sample struct {
+	labels []string
+	value  float64
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// A value that only goes up, like the number of messages sent.
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*sample
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		desc:   desc{name, help, labels},
+		values: make(map[string]*sample),
+	}
+	Default.register(c)
+	return c
+}
+
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+func (c *Counter) Add(v float64, labels ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("counter `%s` can't go down", c.Name))
+	}
+	key := c.key(labels)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.values[key]
+	if !ok {
+		s = &sample{labels: append([]string(nil), labels...)}
+		c.values[key] = s
+	}
+	s.value += v
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.header(w, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		s := c.values[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.Name, c.labels(s.labels), formatValue(s.value))
+	}
+}
+
+// A gauge that's read when the metrics are scraped, like the number
+// of players that are connected. `collect` returns a value for each
+// set of label values.
+type GaugeFunc struct {
+	desc
+	collect func() []Sample
+}
+
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+func NewGaugeFunc(name, help string, collect func() []Sample, labels ...string) *GaugeFunc {
+	g := &GaugeFunc{
+		desc:    desc{name, help, labels},
+		collect: collect,
+	}
+	Default.register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	g.header(w, "gauge")
+	samples := g.collect()
+	sort.Slice(samples, func(i, j int) bool {
+		return g.key(samples[i].Labels) < g.key(samples[j].Labels)
+	})
+	for _, s := range samples {
+		fmt.Fprintf(w, "%s%s %s\n", g.Name, g.labels(s.Labels), formatValue(s.Value))
+	}
+}
+
+// The distribution of a value, like how long a request takes.
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramSample
+}
+
+type histogramSample struct {
+	labels []string
+	// The count of each bucket, which isn't cumulative until it's written.
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Uses the [DefaultBuckets] when `buckets` is nil.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &Histogram{
+		desc:    desc{name, help, labels},
+		buckets: buckets,
+		values:  make(map[string]*histogramSample),
+	}
+	Default.register(h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64, labels ...string) {
+	key := h.key(labels)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.values[key]
+	if !ok {
+		s = &histogramSample{
+			labels: append([]string(nil), labels...),
+			counts: make([]uint64, len(h.buckets)),
+		}
+		h.values[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.header(w, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.values) {
+		s := h.values[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.Name, h.labels(s.labels, "le", formatValue(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.Name, h.labels(s.labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.Name, h.labels(s.labels), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.Name, h.labels(s.labels), s.count)
+	}
+}
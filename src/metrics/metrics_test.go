@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// The metrics are written in name order, and the samples of each in
+// the order of their label values.
+func TestWriteText(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics func() []collector
+		want    string
+	}{
+		{
+			name: "a counter without labels",
+			metrics: func() []collector {
+				c := NewCounter("trivial_games_total", "Games created.")
+				c.Inc()
+				c.Add(2)
+				return []collector{c}
+			},
+			want: `# HELP trivial_games_total Games created.
+# TYPE trivial_games_total counter
+trivial_games_total 3
+`,
+		},
+		{
+			name: "escaped help and labels",
+			metrics: func() []collector {
+				c := NewCounter("trivial_requests_total", "Requests by path\\method,\none per line.", "path", "method")
+				c.Inc("/games/\"quoted\"", "GET")
+				c.Inc("/back\\slash\nnewline", "POST")
+				return []collector{c}
+			},
+			want: `# HELP trivial_requests_total Requests by path\\method,\none per line.
+# TYPE trivial_requests_total counter
+trivial_requests_total{path="/back\\slash\nnewline",method="POST"} 1
+trivial_requests_total{path="/games/\"quoted\"",method="GET"} 1
+`,
+		},
+		{
+			name: "a gauge func",
+			metrics: func() []collector {
+				g := NewGaugeFunc("trivial_players", "Players by game.", func() []Sample {
+					return []Sample{
+						{Labels: []string{"trivia"}, Value: 3},
+						{Labels: []string{"quiz"}, Value: 0.5},
+						{Labels: []string{"empty"}, Value: math.Inf(1)},
+					}
+				}, "game")
+				return []collector{g}
+			},
+			want: `# HELP trivial_players Players by game.
+# TYPE trivial_players gauge
+trivial_players{game="empty"} +Inf
+trivial_players{game="quiz"} 0.5
+trivial_players{game="trivia"} 3
+`,
+		},
+		{
+			name: "a histogram's cumulative buckets, sum and count",
+			metrics: func() []collector {
+				h := NewHistogram("trivial_answer_seconds", "Time to answer.", []float64{1, 0.25, 0.5}, "game")
+				for _, v := range []float64{0.25, 0.5, 0.75, 2} {
+					h.Observe(v, "trivia")
+				}
+				h.Observe(0.1, `"quiz"`)
+				return []collector{h}
+			},
+			want: `# HELP trivial_answer_seconds Time to answer.
+# TYPE trivial_answer_seconds histogram
+trivial_answer_seconds_bucket{game="\"quiz\"",le="0.25"} 1
+trivial_answer_seconds_bucket{game="\"quiz\"",le="0.5"} 1
+trivial_answer_seconds_bucket{game="\"quiz\"",le="1"} 1
+trivial_answer_seconds_bucket{game="\"quiz\"",le="+Inf"} 1
+trivial_answer_seconds_sum{game="\"quiz\""} 0.1
+trivial_answer_seconds_count{game="\"quiz\""} 1
+trivial_answer_seconds_bucket{game="trivia",le="0.25"} 1
+trivial_answer_seconds_bucket{game="trivia",le="0.5"} 2
+trivial_answer_seconds_bucket{game="trivia",le="1"} 3
+trivial_answer_seconds_bucket{game="trivia",le="+Inf"} 4
+trivial_answer_seconds_sum{game="trivia"} 3.5
+trivial_answer_seconds_count{game="trivia"} 4
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Registry{}
+			for _, c := range tt.metrics() {
+				r.register(c)
+			}
+			var b strings.Builder
+			r.WriteText(&b)
+			if got := b.String(); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// A metric registered again with the same name replaces the first,
+// and the registry is served with the text format's content type.
+func TestServeHTTP(t *testing.T) {
+	r := &Registry{}
+	first := NewCounter("trivial_b_total", "The first.")
+	first.Inc()
+	r.register(first)
+	r.register(NewCounter("trivial_b_total", "The second."))
+	r.register(NewCounter("trivial_a_total", "Sorted first."))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("got the content type %q", ct)
+	}
+	want := `# HELP trivial_a_total Sorted first.
+# TYPE trivial_a_total counter
+# HELP trivial_b_total The second.
+# TYPE trivial_b_total counter
+`
+	if got := w.Body.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -3,8 +3,10 @@ package middleware
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math/rand"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -34,6 +36,18 @@ type APIKey struct {
 	Expired     bool
 }
 
+// The paths that don't need the API key.
+var publicPaths = map[string]bool{
+	"/display":      true,
+	"/events":       true,
+	"/events/poll":  true,
+	"/healthz":      true,
+	"/metrics":      true,
+	"/openapi.json": true,
+	"/readyz":       true,
+	"/ws":           true,
+}
+
 type Authenticator struct {
 	key     *APIKey
 	handler http.Handler
@@ -64,11 +78,29 @@ func (a *Authenticator) checkTokenEquality(token string) error {
 
 func (a *Authenticator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	keyHeader := r.Header.Get("X-TRIVIA-APIKEY")
-	if keyHeader == "" && r.URL.Path == "/" || r.URL.Path == "/ws" {
+	// The browser can't send the header when it loads the media
+	// of a question, which is instead protected by a random id.
+	// Likewise, the display and the game's feed check their own
+	// token, and the OpenAPI document, the metrics and the health
+	// checks (which a scraper or a load balancer can't send the
+	// header for) are public.
+	if keyHeader == "" && r.URL.Path == "/" || publicPaths[r.URL.Path] || strings.HasPrefix(r.URL.Path, "/media/") {
 		a.handler.ServeHTTP(w, r)
 		return
 	}
 	if err := a.checkTokenEquality(keyHeader); err != nil {
+		if keyHeader == "" {
+			authFailures.Inc("missing")
+		} else {
+			authFailures.Inc("bad")
+		}
+		// The API always reports an error as JSON.
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintln(w, `{"error":{"status":401,"message":"bad API key"}}`)
+			return
+		}
 		http.Error(w, "bad API key", http.StatusUnauthorized)
 		return
 	}
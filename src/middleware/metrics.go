@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/btoll/trivial/src/metrics"
+)
+
+var (
+	requestDuration = metrics.NewHistogram(
+		"trivial_http_request_duration_seconds",
+		"How long the HTTP requests took, by the handler's pattern.",
+		nil,
+		"handler", "method", "code")
+	authFailures = metrics.NewCounter(
+		"trivial_auth_failures_total",
+		"Requests that were refused for a missing or bad API key.",
+		"reason")
+)
+
+// Times every request by the pattern of the handler that the mux
+// routes it to, rather than by its path, which would give every
+// player's name its own label.
+type Metrics struct {
+	mux     *http.ServeMux
+	handler http.Handler
+}
+
+// Remembers the status of the response. It can still be hijacked,
+// which the websocket needs.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response can't be hijacked")
+	}
+	// A hijacked connection is switching protocols.
+	w.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w}
+	m.handler.ServeHTTP(sw, r)
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	_, pattern := m.mux.Handler(r)
+	requestDuration.Observe(time.Since(start).Seconds(), pattern, r.Method, strconv.Itoa(sw.status))
+}
+
+// The mux is only used to look up the patterns of the requests, so
+// the handler can be the mux wrapped in any other middleware.
+func NewMetrics(mux *http.ServeMux, handler http.Handler) *Metrics {
+	return &Metrics{mux, handler}
+}
@@ -0,0 +1,263 @@
+package profile
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A player's profile lasts across games. It's claimed by the first
+// player to log in with its name, whose browser is given a token
+// that it must send to log in with that name again. Only a hash of
+// the token is stored.
+type Profile struct {
+	Name       string               `json:"name"`
+	TokenHash  string               `json:"tokenHash"`
+	Created    time.Time            `json:"created"`
+	Games      int                  `json:"games"`
+	Wins       int                  `json:"wins"`
+	Points     int                  `json:"points"`
+	Answered   int                  `json:"answered"`
+	Correct    int                  `json:"correct"`
+	Categories map[string]*Accuracy `json:"categories"`
+}
+
+type Accuracy struct {
+	Answered int `json:"answered"`
+	Correct  int `json:"correct"`
+}
+
+// The percentage of answers that were correct.
+func (a Accuracy) Percent() float64 {
+	if a.Answered == 0 {
+		return 0
+	}
+	return float64(a.Correct) / float64(a.Answered) * 100
+}
+
+// How a player did in a single game, which is added to their profile
+// when the game is finished.
+type Result struct {
+	Name       string
+	Points     int
+	Won        bool
+	Categories map[string]*Accuracy
+}
+
+// A row of the season leaderboard. `Current` is the player's score
+// in the game that's being played.
+type Standing struct {
+	Name       string              `json:"name"`
+	Games      int                 `json:"games"`
+	Wins       int                 `json:"wins"`
+	Points     int                 `json:"points"`
+	Current    int                 `json:"current,omitempty"`
+	Accuracy   float64             `json:"accuracy"`
+	Categories map[string]Accuracy `json:"categories"`
+}
+
+// Keeps every profile in a single JSON file, which is rewritten
+// whenever a profile changes.
+type Store struct {
+	Path     string
+	Profiles map[string]*Profile
+	mu       sync.Mutex
+	// The error of the last save, if it failed.
+	err error
+}
+
+func key(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Opens the store at `path`, which is created when the first
+// profile is saved.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		Path:     path,
+		Profiles: make(map[string]*Profile),
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &s.Profiles); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+// Writes to a temporary file first, so the store is never left
+// half-written. The caller must hold the store's lock.
+func (s *Store) save() error {
+	s.err = s.write()
+	return s.err
+}
+
+func (s *Store) write() error {
+	b, err := json.MarshalIndent(s.Profiles, "", "    ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.Profiles)
+}
+
+// Reports whether the profiles can be kept, which they can't if the
+// last save failed or the store's directory is gone.
+func (s *Store) Check() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	dir := filepath.Dir(s.Path)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	return nil
+}
+
+// Logs a player in to their profile. A name that hasn't been claimed
+// yet is claimed with a new token, which is returned so that it can
+// be given to the player's browser. Otherwise, the token must match.
+func (s *Store) Claim(name, token string) (*Profile, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if profile, ok := s.Profiles[key(name)]; ok {
+		if subtle.ConstantTimeCompare([]byte(hash(token)), []byte(profile.TokenHash)) != 1 {
+			return nil, "", fmt.Errorf("The name `%s` belongs to someone else", name)
+		}
+		return profile, token, nil
+	}
+	token = newToken()
+	profile := &Profile{
+		Name:       strings.TrimSpace(name),
+		TokenHash:  hash(token),
+		Created:    time.Now().UTC(),
+		Categories: make(map[string]*Accuracy),
+	}
+	s.Profiles[key(name)] = profile
+	if err := s.save(); err != nil {
+		return nil, "", err
+	}
+	return profile, token, nil
+}
+
+// Adds the results of a finished game to the players' profiles.
+// A player without a profile is skipped.
+func (s *Store) Record(results []Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, result := range results {
+		profile, ok := s.Profiles[key(result.Name)]
+		if !ok {
+			continue
+		}
+		profile.Games++
+		profile.Points += result.Points
+		if result.Won {
+			profile.Wins++
+		}
+		if profile.Categories == nil {
+			profile.Categories = make(map[string]*Accuracy)
+		}
+		for category, accuracy := range result.Categories {
+			profile.Answered += accuracy.Answered
+			profile.Correct += accuracy.Correct
+			if category == "" {
+				continue
+			}
+			total, ok := profile.Categories[category]
+			if !ok {
+				total = &Accuracy{}
+				profile.Categories[category] = total
+			}
+			total.Answered += accuracy.Answered
+			total.Correct += accuracy.Correct
+		}
+	}
+	return s.save()
+}
+
+// Ranks every profile by wins and then by points. `current` is the
+// score of each player in the game that's being played.
+func (s *Store) Leaderboard(current map[string]int) []*Standing {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	standings := make([]*Standing, 0, len(s.Profiles))
+	for _, profile := range s.Profiles {
+		standing := &Standing{
+			Name:       profile.Name,
+			Games:      profile.Games,
+			Wins:       profile.Wins,
+			Points:     profile.Points,
+			Current:    current[profile.Name],
+			Accuracy:   Accuracy{profile.Answered, profile.Correct}.Percent(),
+			Categories: make(map[string]Accuracy, len(profile.Categories)),
+		}
+		for category, accuracy := range profile.Categories {
+			standing.Categories[category] = *accuracy
+		}
+		standings = append(standings, standing)
+	}
+	sort.Slice(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		if a.Points+a.Current != b.Points+b.Current {
+			return a.Points+a.Current > b.Points+b.Current
+		}
+		return a.Name < b.Name
+	})
+	return standings
+}
@@ -0,0 +1,573 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/btoll/trivial/src/middleware"
+)
+
+// The prefix of every endpoint of the API. Each endpoint takes and
+// responds with JSON, and an error is always reported as:
+//
+//	{"error": {"status": 404, "message": "Player not found."}}
+const APIPrefix = "/api/v1"
+
+// Describes every endpoint, both the API and the original ones.
+// It's served at `/openapi.json`.
+//
+//go:embed openapi.json
+var openAPI []byte
+
+// The largest request body that the API will read.
+const maxRequestSize = 1 << 20
+
+// A question in the body of `POST /api/v1/questions`. The `Kind` is
+// either empty for a multiple choice question, whose `Answers` are
+// the (one-based) positions of the correct choices, `order` for an
+// ordering question, whose `Choices` are in their correct order, or
+// `number` for a numeric question, whose answer is the `Number`, or
+// `text` for a free-form question, which has no choices and whose
+// answer is the `Text`.
+// A wager question has no weight. See [parseQuestion].
+type QuestionRequest struct {
+	Question string   `json:"question"`
+	Media    string   `json:"media,omitempty"`
+	Weight   int      `json:"weight,omitempty"`
+	Wager    bool     `json:"wager,omitempty"`
+	Category string   `json:"category,omitempty"`
+	Kind     string   `json:"kind,omitempty"`
+	Scoring  string   `json:"scoring,omitempty"`
+	Choices  []string `json:"choices,omitempty"`
+	Answers  []int    `json:"answers,omitempty"`
+	Number   *float64 `json:"number,omitempty"`
+	Text     string   `json:"text,omitempty"`
+}
+
+// The `Mode` of a round is either empty, `final` or `buzzer`.
+// See [parseRound].
+type RoundRequest struct {
+	Title      string  `json:"title"`
+	Category   string  `json:"category,omitempty"`
+	Multiplier float64 `json:"multiplier,omitempty"`
+	Mode       string  `json:"mode,omitempty"`
+}
+
+// Either adds `Points` (which can be negative) to the score or sets
+// it to `Score`.
+type ScoreRequest struct {
+	Points *int `json:"points,omitempty"`
+	Score  *int `json:"score,omitempty"`
+}
+
+type ScoreResponse struct {
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+}
+
+// Undoes the host's last `Count` score changes, or the last one.
+type UndoRequest struct {
+	Count int `json:"count,omitempty"`
+}
+
+// Restores the scores to what they were before question `Question`.
+type RestoreRequest struct {
+	Question int `json:"question"`
+}
+
+// The scores once they've been undone or restored, and the events
+// that were undone, most recent first.
+type ScoresResponse struct {
+	Undone []int       `json:"undone,omitempty"`
+	Scores AuditScores `json:"scores"`
+}
+
+type MessageRequest struct {
+	Message string `json:"message"`
+}
+
+type StateRequest struct {
+	State string `json:"state"`
+}
+
+type ErrorResponse struct {
+	Error *StatusError `json:"error"`
+}
+
+func (q QuestionRequest) toQuestion() (CurrentQuestion, error) {
+	question := CurrentQuestion{
+		Question:  strings.TrimSpace(q.Question),
+		Media:     q.Media,
+		Category:  q.Category,
+		Wager:     q.Wager,
+		Choices:   q.Choices,
+		Responses: make(map[string]*Response),
+	}
+	if question.Question == "" {
+		return CurrentQuestion{}, statusErrorf(http.StatusBadRequest, "A question is required")
+	}
+	if !q.Wager {
+		if q.Weight <= 0 {
+			return CurrentQuestion{}, statusErrorf(http.StatusBadRequest, "The weight must be greater than zero")
+		}
+		question.Weight = q.Weight
+	}
+	switch q.Kind {
+	case "":
+		// The top bit of the answer's bitmap is reserved.
+		if len(q.Choices) < 2 || len(q.Choices) > 15 {
+			return CurrentQuestion{}, statusErrorf(http.StatusBadRequest, "A multiple choice question needs between 2 and 15 choices")
+		}
+		if len(q.Answers) == 0 {
+			return CurrentQuestion{}, statusErrorf(http.StatusBadRequest, "A multiple choice question needs an answer")
+		}
+		answers := make([]string, len(q.Answers))
+		for i, answer := range q.Answers {
+			if answer < 1 || answer > len(q.Choices) {
+				return CurrentQuestion{}, statusErrorf(http.StatusBadRequest, "There is no choice %d", answer)
+			}
+			answers[i] = strconv.Itoa(answer)
+		}
+		bitmap := makeBitmap(answers)
+		// If there is more than one answer than it is a multiple
+		// choice question with more than one right answer.
+		// As such, we need to encode this into the bitmap, so the
+		// UI can tell the difference between a multiple choice
+		// question with only one right answer and one with more
+		// than one.
+		// A bit value of `10000000 00000000` will instruct the UI
+		// to make checkbox options, while a bit value of
+		// `00000000 00000000` will instruct it to make radio options.
+		// weeeeeeeeeeeeeeeeeeeee
+		if len(answers) > 1 {
+			bitmap += 1 << 15
+		}
+		question.Answer = bitmap
+	case KindOrder:
+		if len(q.Choices) < 2 {
+			return CurrentQuestion{}, statusErrorf(http.StatusBadRequest, "An ordering question needs at least two choices")
+		}
+		switch q.Scoring {
+		case "", ScoreExact:
+			question.Scoring = ScoreExact
+		case ScorePartial:
+			question.Scoring = ScorePartial
+		default:
+			return CurrentQuestion{}, statusErrorf(http.StatusBadRequest, "Unknown scoring `%s`", q.Scoring)
+		}
+		question.Kind = KindOrder
+		question.Choices, question.Order = shuffle(q.Choices)
+	case KindNumber:
+		if len(q.Choices) > 0 {
+			return CurrentQuestion{}, statusErrorf(http.StatusBadRequest, "A numeric question can't have choices")
+		}
+		if q.Number == nil {
+			return CurrentQuestion{}, statusErrorf(http.StatusBadRequest, "A numeric question needs a number")
+		}
+		question.Kind = KindNumber
+		question.Answer = *q.Number
+	case KindText:
+		if len(q.Choices) > 0 {
+			return CurrentQuestion{}, statusErrorf(http.StatusBadRequest, "A free-form question can't have choices")
+		}
+		text := strings.TrimSpace(q.Text)
+		if text == "" {
+			return CurrentQuestion{}, statusErrorf(http.StatusBadRequest, "A free-form question needs an answer")
+		}
+		question.Kind = KindText
+		question.Answer = text
+	default:
+		return CurrentQuestion{}, statusErrorf(http.StatusBadRequest, "Unknown kind `%s`", q.Kind)
+	}
+	return question, nil
+}
+
+// An API request, along with the parameters of its path.
+type apiRequest struct {
+	*http.Request
+	params map[string]string
+}
+
+// Decodes the JSON body of the request into `v`.
+func (r *apiRequest) decode(v any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return statusErrorf(http.StatusBadRequest, "Malformed request body: %v", err)
+	}
+	return nil
+}
+
+// Handles an API request and returns the status and the body of
+// the response, which is empty when the body is nil.
+// The game's lock is held while it's handled.
+type apiHandler func(game *Game, r *apiRequest) (int, any, error)
+
+type apiRoute struct {
+	method  string
+	pattern string
+	handler apiHandler
+}
+
+// Each segment of a pattern that starts with a colon is a parameter.
+// The `path` is escaped, so a parameter can contain a slash.
+func (route apiRoute) match(path string) (map[string]string, bool) {
+	pattern := strings.Split(strings.Trim(route.pattern, "/"), "/")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, segment := range pattern {
+		if strings.HasPrefix(segment, ":") {
+			param, err := url.PathUnescape(segments[i])
+			if err != nil {
+				return nil, false
+			}
+			params[segment[1:]] = param
+		} else if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func (s *SocketServer) apiRoutes() []apiRoute {
+	return []apiRoute{
+		{http.MethodGet, "/state", s.apiGetState},
+		{http.MethodPut, "/state", s.apiSetState},
+		{http.MethodPost, "/questions", hostOnly(s.apiAskQuestion)},
+		{http.MethodPost, "/questions/current/show", hostOnly(s.apiShowQuestion)},
+		{http.MethodPost, "/questions/current/reveal", s.apiRevealQuestion},
+		{http.MethodGet, "/questions/:n/stats", hostOnly(s.apiGetQuestionStats)},
+		{http.MethodPost, "/rounds", s.apiBeginRound},
+		{http.MethodGet, "/players", s.apiGetPlayers},
+		{http.MethodDelete, "/players/:name", hostOnly(s.apiKickPlayer)},
+		{http.MethodPatch, "/players/:name/score", hostOnly(s.apiUpdatePlayerScore)},
+		{http.MethodPost, "/players/:name/messages", s.apiMessagePlayer},
+		{http.MethodDelete, "/teams/:name", hostOnly(s.apiKickTeam)},
+		{http.MethodPatch, "/teams/:name/score", hostOnly(s.apiUpdateTeamScore)},
+		{http.MethodPost, "/notifications", s.apiNotify},
+		{http.MethodDelete, "/scores", hostOnly(s.apiResetScores)},
+		{http.MethodPost, "/scores/undo", hostOnly(s.apiUndoScores)},
+		{http.MethodPost, "/scores/restore", hostOnly(s.apiRestoreScores)},
+		{http.MethodGet, "/scoreboard", s.apiGetScoreboard},
+		{http.MethodGet, "/scoreboard/history", s.apiGetScoreboardHistory},
+		{http.MethodGet, "/season", s.apiGetSeason},
+		{http.MethodGet, "/report", hostOnly(s.apiGetReport)},
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	if v == nil {
+		w.WriteHeader(status)
+		return
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintln(w, string(b))
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := statusOf(err)
+	b, _ := json.Marshal(ErrorResponse{
+		Error: &StatusError{
+			Status:  status,
+			Message: err.Error(),
+		},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintln(w, string(b))
+}
+
+// Routes every request under [APIPrefix] to its handler. A path
+// that is known but doesn't allow the method is a 405.
+func (s *SocketServer) APIHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.EscapedPath(), APIPrefix)
+	var allowed []string
+	for _, route := range s.apiRoutes() {
+		params, ok := route.match(path)
+		if !ok {
+			continue
+		}
+		if route.method != r.Method {
+			allowed = append(allowed, route.method)
+			continue
+		}
+		apiKey := r.Context().Value("apiKey").(*middleware.APIKey)
+		game, err := s.GetGame(apiKey.Key)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+		game.mu.Lock()
+		status, body, err := route.handler(game, &apiRequest{r, params})
+		game.mu.Unlock()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, status, body)
+		return
+	}
+	if allowed != nil {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, statusErrorf(http.StatusMethodNotAllowed, "%s isn't allowed on %s", r.Method, r.URL.Path))
+		return
+	}
+	writeError(w, statusErrorf(http.StatusNotFound, "There is no endpoint %s", r.URL.Path))
+}
+
+func (s *SocketServer) apiGetState(game *Game, r *apiRequest) (int, any, error) {
+	return http.StatusOK, game.GetState(), nil
+}
+
+// Starts, pauses, resumes or finishes the game.
+func (s *SocketServer) apiSetState(game *Game, r *apiRequest) (int, any, error) {
+	var req StateRequest
+	if err := r.decode(&req); err != nil {
+		return 0, nil, err
+	}
+	switch req.State {
+	case StateRunning, StatePaused, StateFinished:
+	default:
+		return 0, nil, statusErrorf(http.StatusBadRequest, "Unknown state `%s`", req.State)
+	}
+	if err := s.ChangeState(game, "", req.State); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, game.GetState(), nil
+}
+
+func (s *SocketServer) apiAskQuestion(game *Game, r *apiRequest) (int, any, error) {
+	var req QuestionRequest
+	if err := r.decode(&req); err != nil {
+		return 0, nil, err
+	}
+	question, err := req.toQuestion()
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := s.AskQuestion(game, question); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, game.CurrentQuestion, nil
+}
+
+func (s *SocketServer) apiShowQuestion(game *Game, r *apiRequest) (int, any, error) {
+	if err := s.ShowQuestion(game); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, game.CurrentQuestion, nil
+}
+
+func (s *SocketServer) apiRevealQuestion(game *Game, r *apiRequest) (int, any, error) {
+	if err := s.RevealQuestion(game); err != nil {
+		return 0, nil, err
+	}
+	stats, err := game.GetQuestionStats(game.CurrentQuestion.Number)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, stats, nil
+}
+
+func (s *SocketServer) apiGetQuestionStats(game *Game, r *apiRequest) (int, any, error) {
+	n, err := strconv.Atoi(r.params["n"])
+	if err != nil {
+		return 0, nil, statusErrorf(http.StatusBadRequest, "`%s` isn't a question number", r.params["n"])
+	}
+	stats, err := game.GetQuestionStats(n)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, stats, nil
+}
+
+func (s *SocketServer) apiBeginRound(game *Game, r *apiRequest) (int, any, error) {
+	var req RoundRequest
+	if err := r.decode(&req); err != nil {
+		return 0, nil, err
+	}
+	round, err := newRound(req.Title, req.Category, req.Multiplier, req.Mode)
+	if err != nil {
+		return 0, nil, withStatus(http.StatusBadRequest, err)
+	}
+	if err := s.BeginRound(game, round); err != nil {
+		return 0, nil, err
+	}
+	round = game.CurrentRound()
+	fmt.Printf("started round %d `%s`\n", round.Number, round.Title)
+	return http.StatusCreated, round, nil
+}
+
+func (s *SocketServer) apiGetPlayers(game *Game, r *apiRequest) (int, any, error) {
+	return http.StatusOK, game.Players, nil
+}
+
+func (s *SocketServer) apiKickPlayer(game *Game, r *apiRequest) (int, any, error) {
+	player, err := game.findPlayer(r.params["name"])
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, s.KickPlayers(game, actorOf(r.Request), GamePlayers{player})
+}
+
+func (s *SocketServer) apiKickTeam(game *Game, r *apiRequest) (int, any, error) {
+	team, err := game.findTeam(r.params["name"])
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, s.KickPlayers(game, actorOf(r.Request), game.GetTeamPlayers(team.Name))
+}
+
+// The points to add to a score of `current`, given either the points
+// or the new score.
+func (req ScoreRequest) points(current int) (int, error) {
+	switch {
+	case req.Points != nil && req.Score != nil:
+		return 0, statusErrorf(http.StatusBadRequest, "Either the points or the score can be given, not both")
+	case req.Points != nil:
+		return *req.Points, nil
+	case req.Score != nil:
+		return *req.Score - current, nil
+	}
+	return 0, statusErrorf(http.StatusBadRequest, "Either the points or the score is required")
+}
+
+func (s *SocketServer) apiUpdatePlayerScore(game *Game, r *apiRequest) (int, any, error) {
+	var req ScoreRequest
+	if err := r.decode(&req); err != nil {
+		return 0, nil, err
+	}
+	player, err := game.findPlayer(r.params["name"])
+	if err != nil {
+		return 0, nil, err
+	}
+	points, err := req.points(player.Score)
+	if err != nil {
+		return 0, nil, err
+	}
+	score, err := s.AddPlayerPoints(game, actorOf(r.Request), player.Name, points)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, ScoreResponse{player.Name, score}, nil
+}
+
+func (s *SocketServer) apiUpdateTeamScore(game *Game, r *apiRequest) (int, any, error) {
+	var req ScoreRequest
+	if err := r.decode(&req); err != nil {
+		return 0, nil, err
+	}
+	team, err := game.findTeam(r.params["name"])
+	if err != nil {
+		return 0, nil, err
+	}
+	points, err := req.points(team.Score)
+	if err != nil {
+		return 0, nil, err
+	}
+	score, err := s.AddTeamPoints(game, actorOf(r.Request), team.Name, points)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, ScoreResponse{team.Name, score}, nil
+}
+
+func (s *SocketServer) apiMessagePlayer(game *Game, r *apiRequest) (int, any, error) {
+	var req MessageRequest
+	if err := r.decode(&req); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, s.MessagePlayer(game, r.params["name"], req.Message)
+}
+
+func (s *SocketServer) apiNotify(game *Game, r *apiRequest) (int, any, error) {
+	var req MessageRequest
+	if err := r.decode(&req); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, s.NotifyAll(game, req.Message)
+}
+
+func (s *SocketServer) apiResetScores(game *Game, r *apiRequest) (int, any, error) {
+	return http.StatusNoContent, nil, s.ResetScores(game, actorOf(r.Request))
+}
+
+func (s *SocketServer) apiUndoScores(game *Game, r *apiRequest) (int, any, error) {
+	var req UndoRequest
+	if r.ContentLength != 0 {
+		if err := r.decode(&req); err != nil {
+			return 0, nil, err
+		}
+	}
+	if req.Count == 0 {
+		req.Count = 1
+	}
+	undone, err := s.UndoScores(game, actorOf(r.Request), req.Count)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, ScoresResponse{undone, game.auditScores()}, nil
+}
+
+func (s *SocketServer) apiRestoreScores(game *Game, r *apiRequest) (int, any, error) {
+	var req RestoreRequest
+	if err := r.decode(&req); err != nil {
+		return 0, nil, err
+	}
+	if err := s.RestoreScores(game, actorOf(r.Request), req.Question); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, ScoresResponse{Scores: game.auditScores()}, nil
+}
+
+// In teams mode, the teams are ranked instead of the players.
+func (s *SocketServer) apiGetScoreboard(game *Game, r *apiRequest) (int, any, error) {
+	if game.TeamPolicy != "" {
+		return http.StatusOK, game.GetTeamScoreboard(), nil
+	}
+	return http.StatusOK, game.GetScoreboard(), nil
+}
+
+func (s *SocketServer) apiGetScoreboardHistory(game *Game, r *apiRequest) (int, any, error) {
+	return http.StatusOK, game.GetTimeline(), nil
+}
+
+func (s *SocketServer) apiGetSeason(game *Game, r *apiRequest) (int, any, error) {
+	if s.Profiles == nil {
+		return 0, nil, statusErrorf(http.StatusNotFound, "Profiles aren't being kept")
+	}
+	current := make(map[string]int)
+	// A finished game has already been added to the profiles.
+	if game.State != StateFinished {
+		for _, score := range game.GetScoreboard() {
+			current[score.Name] = score.Score
+		}
+	}
+	return http.StatusOK, s.Profiles.Leaderboard(current), nil
+}
+
+func (s *SocketServer) apiGetReport(game *Game, r *apiRequest) (int, any, error) {
+	return http.StatusOK, game.Report(), nil
+}
+
+// Serves the OpenAPI document. Like the gameboard, it doesn't need
+// the API key.
+func (s *SocketServer) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPI)
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The host's commands send the name of whoever runs them in this
+// header, since everyone that controls the game shares its keys.
+// The audited actions need the host key, so only a host can make
+// them, but the name is whatever that host says it is.
+const ActorHeader = "X-Trivia-Actor"
+
+// The host actions that are audited, which are those that change
+// the scores or eject players.
+const (
+	AuditPlayerScore   = "player.score"
+	AuditTeamScore     = "team.score"
+	AuditResetScores   = "scores.reset"
+	AuditUndoScores    = "scores.undo"
+	AuditRestoreScores = "scores.restore"
+	AuditKick          = "player.kick"
+)
+
+// Who made a request to control the game: the name that the host
+// gave in the [ActorHeader] (`host` if they didn't) and their address.
+// The request must have had the host key. See [SocketServer.hostGame].
+type Actor struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+func actorOf(r *http.Request) Actor {
+	name := strings.TrimSpace(r.Header.Get(ActorHeader))
+	if name == "" {
+		name = "host"
+	}
+	return Actor{
+		Name:    name,
+		Address: r.RemoteAddr,
+	}
+}
+
+// A host action, which is recorded once it has succeeded. A score's
+// `Before` and `After` values are numbers, those of a reset, an undo
+// or a restore are every score (see [AuditScores]) and a kicked player
+// goes from `active` to `benched`.
+type AuditEntry struct {
+	Seq     int       `json:"seq"`
+	Time    time.Time `json:"time"`
+	Actor   string    `json:"actor"`
+	Address string    `json:"address"`
+	Action  string    `json:"action"`
+	Target  string    `json:"target,omitempty"`
+	Before  any       `json:"before,omitempty"`
+	After   any       `json:"after,omitempty"`
+}
+
+// The before and after values as JSON, for the printable report.
+func (e AuditEntry) Change() string {
+	before, _ := json.Marshal(e.Before)
+	after, _ := json.Marshal(e.After)
+	return fmt.Sprintf("%s → %s", before, after)
+}
+
+// Every player's and team's score, before and after they're reset.
+type AuditScores struct {
+	Players map[string]int `json:"players"`
+	Teams   map[string]int `json:"teams,omitempty"`
+}
+
+// The caller must hold the game's lock.
+func (g *Game) auditScores() AuditScores {
+	scores := AuditScores{Players: make(map[string]int)}
+	for _, player := range g.Players {
+		scores.Players[player.Name] = player.Score
+	}
+	for _, player := range g.Benched {
+		scores.Players[player.Name] = player.Score
+	}
+	if g.TeamPolicy != "" {
+		scores.Teams = make(map[string]int)
+		for _, team := range g.Teams {
+			scores.Teams[team.Name] = team.Score
+		}
+	}
+	return scores
+}
+
+// Records a host action in the game's journal, so that the audit log
+// is recovered along with the game.
+// The caller must hold the game's lock.
+func (g *Game) audit(actor Actor, action, target string, before, after any) {
+	err := g.emit(EventHostActed, AuditEntry{
+		Actor:   actor.Name,
+		Address: actor.Address,
+		Action:  action,
+		Target:  target,
+		Before:  before,
+		After:   after,
+	})
+	if err != nil {
+		fmt.Println("audit error:", err)
+	}
+}
+
+// Lists the host's actions, oldest first, which can be filtered by
+// `actor`, `action` and `target`, e.g. `/audit?action=player.score&target=alice`.
+func (s *SocketServer) AuditHandler(w http.ResponseWriter, r *http.Request) {
+	game, ok := s.hostGame(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	entries := make([]AuditEntry, 0)
+	game.mu.Lock()
+	for _, entry := range game.Audit {
+		if query.Has("actor") && query.Get("actor") != entry.Actor {
+			continue
+		}
+		if query.Has("action") && query.Get("action") != entry.Action {
+			continue
+		}
+		if query.Has("target") && query.Get("target") != entry.Target {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	game.mu.Unlock()
+	writeJSON(w, http.StatusOK, entries)
+}
@@ -43,8 +43,12 @@ func getItemFromLog(choices []string, num uint16) []string {
 	correctIndices := getBase2Components(removeLastBit(num))
 	t := []string{}
 	for _, v := range correctIndices {
-		log := math.Log2(float64(v))
-		t = append(t, choices[int(log)])
+		log := int(math.Log2(float64(v)))
+		// A tampered guess could set bits beyond the choices.
+		if log < 0 || log >= len(choices) {
+			continue
+		}
+		t = append(t, choices[log])
 	}
 	return t
 }
@@ -58,7 +62,7 @@ func makeBitmap(nums []string) uint16 {
 	for _, d := range nums {
 		n, err := strconv.ParseUint(d, 10, 16)
 		if err != nil {
-			fmt.Sprintln("%s cannot be converted to an integer, ignoring\n", n)
+			fmt.Printf("%s cannot be converted to an integer, ignoring\n", d)
 			continue
 		}
 		// The answers are entered in as one-based in the CSV.
@@ -0,0 +1,249 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The default timings of a buzzer round.
+// See [Game.BuzzerDelay], [Game.BuzzerLockout] and [Game.BuzzerWindow].
+const (
+	DefaultBuzzerDelay   = 3 * time.Second
+	DefaultBuzzerLockout = time.Second
+	DefaultBuzzerWindow  = 5 * time.Second
+)
+
+// In a buzzer round, the players race to buzz in once the question
+// is shown, and only the player that holds the buzz may answer.
+//
+// The buzzers are armed a moment after the question is shown, and a
+// player that buzzes before then is locked out for a short while.
+// Everyone that buzzes is queued in the order that the server
+// received their buzz. A wrong answer, or no answer within the
+// window, passes the buzz to the next in line, and a player only
+// gets one turn per question.
+type BuzzQueue struct {
+	Armed    time.Time
+	Holder   string
+	Window   time.Time
+	Queue    []string
+	Lockouts map[string]time.Time
+	Passed   map[string]bool
+	timer    *gameTimer
+}
+
+// What is published as a `buzzer` event whenever the buzzers are
+// armed or the buzz changes hands. The `Window` is how long (in
+// seconds) the holder has to answer.
+type BuzzerState struct {
+	Number int      `json:"number"`
+	Armed  bool     `json:"armed"`
+	Holder string   `json:"holder,omitempty"`
+	Window int      `json:"window,omitempty"`
+	Queue  []string `json:"queue"`
+	Passed []string `json:"passed"`
+}
+
+func newBuzzQueue(armed time.Time) *BuzzQueue {
+	return &BuzzQueue{
+		Armed:    armed,
+		Queue:    make([]string, 0),
+		Lockouts: make(map[string]time.Time),
+		Passed:   make(map[string]bool),
+	}
+}
+
+func (b *BuzzQueue) stop() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+}
+
+func (b *BuzzQueue) queued(name string) bool {
+	for _, n := range b.Queue {
+		if n == name {
+			return true
+		}
+	}
+	return name == b.Holder
+}
+
+// Queues a player's buzz for the current question.
+// The caller must hold the game's lock.
+func (g *Game) Buzz(player *Player) error {
+	q := &g.CurrentQuestion
+	if err := g.CheckRunning(); err != nil {
+		return err
+	}
+	if !g.IsOpen() || !q.Buzzer {
+		return errors.New("There is nothing to buzz in for")
+	}
+	if q.Buzzes == nil {
+		return errors.New("Wait for the question")
+	}
+	b := q.Buzzes
+	if b.Passed[player.Name] {
+		return errors.New("You've already had your turn")
+	}
+	if b.queued(player.Name) {
+		return errors.New("You've already buzzed in")
+	}
+	now := time.Now()
+	if now.Before(b.Lockouts[player.Name]) {
+		return errors.New("You're locked out, wait a moment")
+	}
+	if now.Before(b.Armed) {
+		b.Lockouts[player.Name] = now.Add(g.BuzzerLockout)
+		return fmt.Errorf("Too early!  You're locked out for %v", g.BuzzerLockout)
+	}
+	b.Queue = append(b.Queue, player.Name)
+	return nil
+}
+
+// Gives the buzz to the next player in line that is still in the
+// game. Returns false if there is no one left in line.
+func (g *Game) nextBuzz() bool {
+	b := g.CurrentQuestion.Buzzes
+	b.Holder = ""
+	for len(b.Queue) > 0 {
+		name := b.Queue[0]
+		b.Queue = b.Queue[1:]
+		if _, err := g.GetPlayer(name); err == nil {
+			b.Holder = name
+			b.Window = time.Now().Add(g.BuzzerWindow)
+			return true
+		}
+	}
+	return false
+}
+
+// The player holds the buzz for the current question.
+func (g *Game) HasBuzz(name string) bool {
+	q := &g.CurrentQuestion
+	return g.IsOpen() && q.Buzzes != nil && q.Buzzes.Holder == name
+}
+
+// A buzzer question is done once it has been answered correctly
+// or every active player has had their turn.
+func (g *Game) buzzerDone() bool {
+	q := &g.CurrentQuestion
+	if q.Buzzes == nil {
+		return false
+	}
+	for _, response := range q.Responses {
+		if response.Correct {
+			return true
+		}
+	}
+	if len(g.Players) == 0 {
+		return false
+	}
+	for _, player := range g.Players {
+		if !q.Buzzes.Passed[player.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Game) buzzerState() BuzzerState {
+	b := g.CurrentQuestion.Buzzes
+	state := BuzzerState{
+		Number: g.CurrentQuestion.Number,
+		Armed:  !time.Now().Before(b.Armed),
+		Holder: b.Holder,
+		Queue:  b.Queue,
+		Passed: make([]string, 0, len(b.Passed)),
+	}
+	if b.Holder != "" {
+		state.Window = int(g.BuzzerWindow.Seconds())
+	}
+	for name := range b.Passed {
+		state.Passed = append(state.Passed, name)
+	}
+	return state
+}
+
+// Arms the buzzers of the current question after the game's delay.
+// The caller must hold the game's lock.
+func (s *SocketServer) armBuzzer(game *Game) {
+	number := game.CurrentQuestion.Number
+	b := newBuzzQueue(time.Now().Add(game.BuzzerDelay))
+	game.CurrentQuestion.Buzzes = b
+	b.timer = newGameTimer(game.BuzzerDelay, func() {
+		game.mu.Lock()
+		defer game.mu.Unlock()
+		if game.IsOpen() && game.CurrentQuestion.Number == number {
+			if err := s.publishBuzzer(game); err != nil {
+				fmt.Println(err)
+			}
+		}
+	})
+}
+
+// Queues a player's buzz and, if no one holds the buzz, gives it
+// to them right away.
+// The caller must hold the game's lock.
+func (s *SocketServer) Buzz(game *Game, player *Player) error {
+	if err := game.Buzz(player); err != nil {
+		return err
+	}
+	if game.CurrentQuestion.Buzzes.Holder == "" {
+		return s.grantBuzz(game)
+	}
+	return s.publishBuzzer(game)
+}
+
+// Passes the buzz on from its holder, who has either answered
+// wrong, run out of time or left the game. The question is closed
+// once everyone has had their turn.
+// The caller must hold the game's lock.
+func (s *SocketServer) PassBuzz(game *Game) error {
+	b := game.CurrentQuestion.Buzzes
+	b.stop()
+	if b.Holder != "" {
+		b.Passed[b.Holder] = true
+		b.Holder = ""
+	}
+	if game.ReadyToClose() {
+		return s.Reveal(game)
+	}
+	return s.grantBuzz(game)
+}
+
+// Gives the buzz to the next player in line, who is told that it's
+// their turn, and passes it on if they don't answer in time.
+// The caller must hold the game's lock.
+func (s *SocketServer) grantBuzz(game *Game) error {
+	if game.nextBuzz() {
+		b := game.CurrentQuestion.Buzzes
+		number, holder := game.CurrentQuestion.Number, b.Holder
+		b.timer = newGameTimer(game.BuzzerWindow, func() {
+			game.mu.Lock()
+			defer game.mu.Unlock()
+			if game.CurrentQuestion.Number == number && game.HasBuzz(holder) {
+				if err := s.PassBuzz(game); err != nil {
+					fmt.Println(err)
+				}
+			}
+		})
+		player, _ := game.GetPlayer(holder)
+		err := s.tell(game, player, ServerMessage{
+			Type: "notify_player",
+			Data: fmt.Sprintf("You have the buzz!  Answer within %v", game.BuzzerWindow),
+		})
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	return s.publishBuzzer(game)
+}
+
+func (s *SocketServer) publishBuzzer(game *Game) error {
+	return s.Publish(game, ServerMessage{
+		Type: "buzzer",
+		Data: game.buzzerState(),
+	})
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// A buzzer round with alice, bob and carl. The buzzers aren't armed
+// and no one's window runs out until the test says so, so no timer
+// goes off while it plays.
+func newBuzzerGame(t *testing.T, lockout time.Duration) (*SocketServer, *Game) {
+	t.Helper()
+	s, g := newTestGame(t, func(g *Game) {
+		g.BuzzerDelay = time.Hour
+		g.BuzzerLockout = lockout
+		g.BuzzerWindow = time.Hour
+	})
+	join(t, g, "alice", "bob", "carl")
+	mustDo(t, s.SetState(g, StateRunning))
+	mustDo(t, s.StartRound(g, &Round{Title: "Lightning Round", Multiplier: 2, Buzzer: true}))
+	ask(t, s, g, "What's the capital of France?|10|Paris")
+	return s, g
+}
+
+func arm(g *Game) {
+	g.CurrentQuestion.Buzzes.Armed = time.Now()
+}
+
+func buzz(t *testing.T, s *SocketServer, g *Game, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		mustDo(t, s.Buzz(g, player(t, g, name)))
+	}
+}
+
+func checkHolder(t *testing.T, g *Game, want string) {
+	t.Helper()
+	if holder := g.CurrentQuestion.Buzzes.Holder; holder != want {
+		t.Errorf("%q holds the buzz, want %q", holder, want)
+	}
+}
+
+func TestBuzzerArming(t *testing.T) {
+	tests := []struct {
+		name    string
+		lockout time.Duration
+		early   bool
+		err     string
+	}{
+		{"once the buzzers are armed", time.Hour, false, ""},
+		{"before the buzzers are armed", time.Hour, true, "locked out, wait"},
+		{"once the lockout has passed", 0, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, g := newBuzzerGame(t, tt.lockout)
+			alice := player(t, g, "alice")
+			if tt.early {
+				err := s.Buzz(g, alice)
+				if err == nil || !strings.HasPrefix(err.Error(), "Too early!") {
+					t.Fatalf("buzzing early got %v, want to be locked out", err)
+				}
+				checkHolder(t, g, "")
+			}
+			arm(g)
+			err := s.Buzz(g, alice)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got %v, want an error with %q", err, tt.err)
+				}
+				checkHolder(t, g, "")
+				return
+			}
+			mustDo(t, err)
+			checkHolder(t, g, "alice")
+		})
+	}
+}
+
+func TestPassBuzz(t *testing.T) {
+	tests := []struct {
+		name    string
+		play    func(t *testing.T, s *SocketServer, g *Game)
+		holder  string
+		closed  bool
+		queue   []string
+		scores  map[string]int
+		buzzErr string
+	}{
+		{
+			name:   "the first to buzz in holds the buzz",
+			play:   func(t *testing.T, s *SocketServer, g *Game) {},
+			holder: "alice",
+			queue:  []string{"bob", "carl"},
+		},
+		{
+			name: "a wrong answer passes the buzz on",
+			play: func(t *testing.T, s *SocketServer, g *Game) {
+				guess(t, s, g, "alice", "Lyon")
+			},
+			holder: "bob",
+			queue:  []string{"carl"},
+		},
+		{
+			name: "a correct answer closes the question",
+			play: func(t *testing.T, s *SocketServer, g *Game) {
+				guess(t, s, g, "alice", "Lyon")
+				guess(t, s, g, "bob", "Paris")
+			},
+			closed: true,
+			// Only a correct answer earns points, times the round's
+			// multiplier.
+			scores: map[string]int{"alice": 0, "bob": 20, "carl": 0},
+		},
+		{
+			name: "the question closes once everyone has had their turn",
+			play: func(t *testing.T, s *SocketServer, g *Game) {
+				guess(t, s, g, "alice", "Lyon")
+				guess(t, s, g, "bob", "Nice")
+				guess(t, s, g, "carl", "Lille")
+			},
+			closed: true,
+			scores: map[string]int{"alice": 0, "bob": 0, "carl": 0},
+		},
+		{
+			name: "a kicked holder passes the buzz on",
+			play: func(t *testing.T, s *SocketServer, g *Game) {
+				mustDo(t, s.KickPlayers(g, host, GamePlayers{player(t, g, "alice")}))
+			},
+			holder: "bob",
+			queue:  []string{"carl"},
+		},
+		{
+			name: "a kicked player loses their place in line",
+			play: func(t *testing.T, s *SocketServer, g *Game) {
+				mustDo(t, s.KickPlayers(g, host, GamePlayers{player(t, g, "bob")}))
+				guess(t, s, g, "alice", "Lyon")
+			},
+			holder: "carl",
+			queue:  []string{},
+		},
+		{
+			name: "a player only gets one turn",
+			play: func(t *testing.T, s *SocketServer, g *Game) {
+				guess(t, s, g, "alice", "Lyon")
+			},
+			holder:  "bob",
+			queue:   []string{"carl"},
+			buzzErr: "You've already had your turn",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, g := newBuzzerGame(t, time.Hour)
+			arm(g)
+			buzz(t, s, g, "alice", "bob", "carl")
+			tt.play(t, s, g)
+			if g.IsOpen() == tt.closed {
+				t.Fatalf("the question is open = %v, want %v", g.IsOpen(), !tt.closed)
+			}
+			if tt.closed {
+				for name, want := range tt.scores {
+					if got := player(t, g, name).Score; got != want {
+						t.Errorf("%s scored %d, want %d", name, got, want)
+					}
+				}
+				return
+			}
+			checkHolder(t, g, tt.holder)
+			if queue := g.CurrentQuestion.Buzzes.Queue; strings.Join(queue, ",") != strings.Join(tt.queue, ",") {
+				t.Errorf("got the queue %v, want %v", queue, tt.queue)
+			}
+			if tt.buzzErr != "" {
+				if err := s.Buzz(g, player(t, g, "alice")); err == nil || err.Error() != tt.buzzErr {
+					t.Errorf("buzzing again got %v, want %q", err, tt.buzzErr)
+				}
+			}
+		})
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
@@ -75,7 +76,6 @@ func generatePrivateKey(ecdsaCurve string, ed25519Key bool, rsaBits int) (any, e
 	default:
 		return nil, fmt.Errorf("Unrecognized elliptic curve: %s", ecdsaCurve)
 	}
-	return nil, nil
 }
 
 func GenerateCert(cert TLSCert) {
@@ -88,7 +88,7 @@ func GenerateCert(cert TLSCert) {
 	}
 	priv, err := generatePrivateKey(cert.EcdsaCurve, cert.Ed25519Key, cert.RsaBits)
 	if err != nil {
-		fmt.Println("%+v", cert)
+		fmt.Printf("%+v\n", cert)
 		log.Fatalf("Failed to generate private key: %v", err)
 	}
 
@@ -183,3 +183,37 @@ func GenerateCert(cert TLSCert) {
 	}
 	// log.Print("wrote key.pem\n")
 }
+
+// Returns the SHA-256 fingerprint of the certificate in the PEM file,
+// which a client can pin instead of trusting a self-signed certificate.
+func CertFingerprint(path string) (string, error) {
+	cert, err := ReadCert(path)
+	if err != nil {
+		return "", err
+	}
+	return Fingerprint(cert.Raw), nil
+}
+
+// Reads the (first) certificate in the PEM file.
+func ReadCert(path string) (*x509.Certificate, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s doesn't contain a certificate", path)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// Formats the SHA-256 fingerprint of a DER-encoded certificate as
+// colon-separated hex, like `openssl x509 -fingerprint -sha256`.
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	hex := make([]string, len(sum))
+	for i, b := range sum {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(hex, ":")
+}
@@ -0,0 +1,327 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/btoll/trivial/src/webhook"
+)
+
+// An error that knows the HTTP status that it should be reported
+// with. Any other error is an internal server error.
+type StatusError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+func statusErrorf(status int, format string, a ...any) *StatusError {
+	return &StatusError{
+		Status:  status,
+		Message: fmt.Sprintf(format, a...),
+	}
+}
+
+// Wraps an error with a status, unless it already has one.
+func withStatus(status int, err error) error {
+	var e *StatusError
+	if err == nil || errors.As(err, &e) {
+		return err
+	}
+	return &StatusError{Status: status, Message: err.Error()}
+}
+
+func statusOf(err error) int {
+	var e *StatusError
+	if errors.As(err, &e) {
+		return e.Status
+	}
+	return http.StatusInternalServerError
+}
+
+// The operations below are how the host controls the game, whether
+// through the API or the original endpoints.
+// The caller must hold the game's lock.
+
+// Asks a new question, resolving its media (if any) in the deck.
+func (s *SocketServer) AskQuestion(game *Game, question CurrentQuestion) error {
+	if err := game.CheckRunning(); err != nil {
+		return withStatus(http.StatusConflict, err)
+	}
+	if question.Media != "" {
+		path, kind, err := s.resolveMedia(question.Media)
+		if err != nil {
+			return withStatus(http.StatusBadRequest, err)
+		}
+		question.Media = path
+		question.MediaID = newRandomID()
+		question.MediaType = kind
+	}
+	return s.Ask(game, question)
+}
+
+func (s *SocketServer) BeginRound(game *Game, round *Round) error {
+	if err := game.CheckRunning(); err != nil {
+		return withStatus(http.StatusConflict, err)
+	}
+	return s.StartRound(game, round)
+}
+
+func (s *SocketServer) ShowQuestion(game *Game) error {
+	if err := game.CheckRunning(); err != nil {
+		return withStatus(http.StatusConflict, err)
+	}
+	return withStatus(http.StatusConflict, s.Show(game))
+}
+
+func (s *SocketServer) RevealQuestion(game *Game) error {
+	return withStatus(http.StatusConflict, s.Reveal(game))
+}
+
+// Moves the game to a new state, but only from the state `from`
+// when it's given.
+func (s *SocketServer) ChangeState(game *Game, from, state string) error {
+	if from != "" && game.State != from {
+		return statusErrorf(http.StatusConflict, "The game is %s", game.State)
+	}
+	if err := s.SetState(game, state); err != nil {
+		return withStatus(http.StatusConflict, err)
+	}
+	fmt.Printf("game `%s` is %s\n", game.Name, game.State)
+	return nil
+}
+
+func (g *Game) findPlayer(name string) (*Player, error) {
+	player, err := g.GetPlayer(name)
+	return player, withStatus(http.StatusNotFound, err)
+}
+
+func (g *Game) findTeam(name string) (*Team, error) {
+	team, err := g.GetTeam(name)
+	return team, withStatus(http.StatusNotFound, err)
+}
+
+// Logs the players out and benches them. The current question is
+// revealed if they were the last ones that everyone was waiting on.
+func (s *SocketServer) KickPlayers(game *Game, actor Actor, players GamePlayers) error {
+	for _, player := range players {
+		err := s.tell(game, player, ServerMessage{
+			Type: "logout",
+			Data: "",
+		})
+		if err != nil {
+			return err
+		}
+		captain := game.captainOf(player)
+		if err := game.Bench(player, "kicked"); err != nil {
+			return err
+		}
+		if err := s.publishCaptain(game, player, captain); err != nil {
+			return err
+		}
+		game.audit(actor, AuditKick, player.Name, "active", "benched")
+		fmt.Println("killing player", player.Name)
+		s.firePlayer(game, webhook.PlayerLeft, player, "kicked")
+		s.benched(game, player)
+	}
+	return s.Publish(game, ServerMessage{
+		Type: "update_scoreboard",
+		Data: game.GetScoreboard(),
+	})
+}
+
+// Benches a player that has left the game, whether their websocket
+// was closed or they went idle, and lets everyone know.
+// The caller must hold the game's lock.
+func (s *SocketServer) leave(game *Game, player *Player, reason string) error {
+	captain := game.captainOf(player)
+	if err := game.Bench(player, reason); err != nil {
+		return err
+	}
+	s.firePlayer(game, webhook.PlayerLeft, player, reason)
+	err := s.Publish(game, ServerMessage{
+		Type: "player_delete",
+		Data: game.Players,
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.publishCaptain(game, player, captain); err != nil {
+		return err
+	}
+	s.benched(game, player)
+	return nil
+}
+
+// Moves the game along after a player has been benched, whether they
+// left or were kicked, since they may have been the last one that
+// everyone was waiting on: their buzz is passed, and the question is
+// shown or closed if no one else is left to wager or guess.
+func (s *SocketServer) benched(game *Game, player *Player) {
+	if game.HasBuzz(player.Name) {
+		if err := s.PassBuzz(game); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if game.ReadyToShow() {
+		if err := s.Show(game); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if game.ReadyToClose() {
+		if err := s.Reveal(game); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+func (s *SocketServer) MessagePlayer(game *Game, name, message string) error {
+	player, err := game.findPlayer(name)
+	if err != nil {
+		return err
+	}
+	return s.tell(game, player, ServerMessage{
+		Type: "notify_player",
+		Data: message,
+	})
+}
+
+func (s *SocketServer) NotifyAll(game *Game, message string) error {
+	return s.Publish(game, ServerMessage{
+		Type: "notify_all",
+		Data: message,
+	})
+}
+
+func checkScoresUnlocked(game *Game) error {
+	if game.State == StateFinished {
+		return statusErrorf(http.StatusConflict, "The game is over, the scores are locked")
+	}
+	return nil
+}
+
+// Adds the points (which can be negative) to a player's score.
+func (s *SocketServer) AddPlayerPoints(game *Game, actor Actor, name string, points int) (int, error) {
+	if err := checkScoresUnlocked(game); err != nil {
+		return 0, err
+	}
+	player, err := game.findPlayer(name)
+	if err != nil {
+		return 0, err
+	}
+	before := player.Score
+	score, err := game.UpdatePlayerScore(player.Name, points)
+	if err != nil {
+		return 0, err
+	}
+	game.audit(actor, AuditPlayerScore, player.Name, before, score)
+	err = s.Publish(game, ServerMessage{
+		Type: "update_scoreboard",
+		Data: game.GetScoreboard(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	fmt.Printf("Added %d points to player `%s`.\n", points, player.Name)
+	return score, nil
+}
+
+// Adds the points (which can be negative) to a team's score.
+func (s *SocketServer) AddTeamPoints(game *Game, actor Actor, name string, points int) (int, error) {
+	if err := checkScoresUnlocked(game); err != nil {
+		return 0, err
+	}
+	team, err := game.findTeam(name)
+	if err != nil {
+		return 0, err
+	}
+	before := team.Score
+	score, err := game.UpdateTeamScore(team.Name, points)
+	if err != nil {
+		return 0, err
+	}
+	game.audit(actor, AuditTeamScore, team.Name, before, score)
+	err = s.Publish(game, ServerMessage{
+		Type: "update_team_scoreboard",
+		Data: game.GetTeamScoreboard(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	fmt.Printf("Added %d points to team `%s`.\n", points, team.Name)
+	return score, nil
+}
+
+// Undoes the host's last `n` score changes (see [Game.UndoScores]) and
+// publishes the scores to everyone.
+func (s *SocketServer) UndoScores(game *Game, actor Actor, n int) ([]int, error) {
+	if err := checkScoresUnlocked(game); err != nil {
+		return nil, err
+	}
+	if n < 1 {
+		return nil, statusErrorf(http.StatusBadRequest, "At least one score change must be undone")
+	}
+	before := game.auditScores()
+	undone, err := game.UndoScores(n)
+	if err != nil {
+		return nil, withStatus(http.StatusConflict, err)
+	}
+	seqs := make([]string, len(undone))
+	for i, seq := range undone {
+		seqs[i] = strconv.Itoa(seq)
+	}
+	game.audit(actor, AuditUndoScores, "events "+strings.Join(seqs, ", "), before, game.auditScores())
+	fmt.Printf("undid %d score changes\n", len(undone))
+	return undone, s.publishScores(game)
+}
+
+// Restores every score to what it was before question `number` was
+// asked and publishes the scores to everyone.
+func (s *SocketServer) RestoreScores(game *Game, actor Actor, number int) error {
+	if err := checkScoresUnlocked(game); err != nil {
+		return err
+	}
+	before := game.auditScores()
+	if err := game.RestoreScores(number); err != nil {
+		return withStatus(http.StatusNotFound, err)
+	}
+	game.audit(actor, AuditRestoreScores, fmt.Sprintf("question %d", number), before, game.auditScores())
+	fmt.Printf("restored the scores from before question %d\n", number)
+	return s.publishScores(game)
+}
+
+func (s *SocketServer) publishScores(game *Game) error {
+	err := s.Publish(game, ServerMessage{
+		Type: "update_scoreboard",
+		Data: game.GetScoreboard(),
+	})
+	if err != nil || game.TeamPolicy == "" {
+		return err
+	}
+	return s.Publish(game, ServerMessage{
+		Type: "update_team_scoreboard",
+		Data: game.GetTeamScoreboard(),
+	})
+}
+
+// Sets every player's and team's score back to zero.
+func (s *SocketServer) ResetScores(game *Game, actor Actor) error {
+	if err := checkScoresUnlocked(game); err != nil {
+		return err
+	}
+	before := game.auditScores()
+	if err := game.ResetScores(); err != nil {
+		return err
+	}
+	game.audit(actor, AuditResetScores, "", before, game.auditScores())
+	return s.Publish(game, ServerMessage{
+		Type: "update_scoreboard",
+		Data: game.GetScoreboard(),
+	})
+}
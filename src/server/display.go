@@ -0,0 +1,196 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/websocket"
+)
+
+// What the `display` template is rendered with.
+type DisplayPage struct {
+	Game     string
+	Location URL
+	Token    string
+}
+
+// How many of the active players have answered the current question,
+// which is published to the displays as an `answered` event.
+type Answered struct {
+	Number   int `json:"number"`
+	Answered int `json:"answered"`
+	Players  int `json:"players"`
+}
+
+// Everything a display needs to catch up when it connects in the
+// middle of a game. The `Question` is the same as what's published
+// in a `question` event, and it's only given once it has been shown.
+type DisplaySnapshot struct {
+	Game           string         `json:"game"`
+	State          GameState      `json:"state"`
+	Question       string         `json:"question,omitempty"`
+	Answered       Answered       `json:"answered"`
+	Scoreboard     Scoreboard     `json:"scoreboard"`
+	TeamScoreboard TeamScoreboard `json:"teamScoreboard,omitempty"`
+}
+
+// Each game has its own display key, which is separate from the API
+// key so that the page can be opened on a TV without giving away
+// control of the game.
+func (s *SocketServer) GetGameByDisplayKey(key string) (*Game, error) {
+	if key == "" {
+		return nil, errors.New("display key is an empty string")
+	}
+	for _, game := range s.Games {
+		if game.DisplayKey == key {
+			return game, nil
+		}
+	}
+	return nil, errors.New("Bad display key")
+}
+
+// The caller must hold the game's lock.
+func (g *Game) AddDisplay(socket *websocket.Conn) {
+	g.Displays = append(g.Displays, socket)
+}
+
+// Returns false if the socket isn't one of the game's displays.
+// The caller must hold the game's lock.
+func (g *Game) RemoveDisplay(socket *websocket.Conn) bool {
+	for i, display := range g.Displays {
+		if display == socket {
+			g.Displays = append(g.Displays[:i], g.Displays[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// The displays aren't players, so they're never counted as having
+// to answer.
+func (g *Game) GetAnswered() Answered {
+	answered := Answered{
+		Number:  g.CurrentQuestion.Number,
+		Players: len(g.Players),
+	}
+	for _, player := range g.Players {
+		if _, ok := g.CurrentQuestion.Responses[player.Name]; ok {
+			answered.Answered++
+		}
+	}
+	return answered
+}
+
+// The caller must hold the game's lock.
+func (g *Game) Snapshot() (*DisplaySnapshot, error) {
+	snapshot := &DisplaySnapshot{
+		Game:       g.Name,
+		State:      g.GetState(),
+		Answered:   g.GetAnswered(),
+		Scoreboard: g.GetScoreboard(),
+	}
+	if g.TeamPolicy != "" {
+		snapshot.TeamScoreboard = g.GetTeamScoreboard()
+	}
+	if g.IsOpen() && g.CurrentQuestion.Phase == PhaseOpen {
+		b, err := json.Marshal(g.CurrentQuestion.shown())
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Question = string(b)
+	}
+	return snapshot, nil
+}
+
+// Notifies only the displays (and the host's watchers) of an event
+// that the players have no use for.
+func (s *SocketServer) PublishDisplays(game *Game, msg ServerMessage) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	broadcast(game.Displays, msg.Type, b)
+	broadcast(game.Watchers, msg.Type, b)
+	game.Feed.publish(msg.Type, b)
+	return nil
+}
+
+func broadcast(sockets []*websocket.Conn, msgType string, b []byte) {
+	for _, socket := range sockets {
+		go func(socket *websocket.Conn) {
+			if err := write(socket, msgType, b); err != nil {
+				fmt.Println(err)
+			}
+		}(socket)
+	}
+}
+
+// Sends the display everything it needs to catch up, and from then
+// on every event that's published to the players.
+func (s *SocketServer) connectDisplay(socket *websocket.Conn, key string) {
+	game, err := s.GetGameByDisplayKey(key)
+	if err != nil {
+		err = s.Message(socket, ServerMessage{
+			Type: "error",
+			Data: err.Error(),
+		})
+		if err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+	game.mu.Lock()
+	defer game.mu.Unlock()
+	snapshot, err := game.Snapshot()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	game.AddDisplay(socket)
+	err = s.Message(socket, ServerMessage{
+		Type: "display",
+		Data: snapshot,
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Printf("a display is watching game `%s`\n", game.Name)
+}
+
+// Returns false if the socket isn't a display of any game.
+func (s *SocketServer) disconnectDisplay(socket *websocket.Conn) bool {
+	for _, game := range s.Games {
+		game.mu.Lock()
+		removed := game.RemoveDisplay(socket)
+		game.mu.Unlock()
+		if removed {
+			return true
+		}
+	}
+	return false
+}
+
+// Serves the read-only display of a game at `/display?token=`, which
+// is meant to be projected for the whole room to see. It bypasses the
+// [middleware.Authenticator], since a TV's browser can't send the API
+// key header, and instead checks the game's display key.
+func (s *SocketServer) DisplayHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	game, err := s.GetGameByDisplayKey(token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = s.Tpl.ExecuteTemplate(w, "display", DisplayPage{
+		Game:     game.Name,
+		Location: s.Location,
+		Token:    token,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
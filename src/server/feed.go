@@ -0,0 +1,494 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btoll/trivial/src/webhook"
+)
+
+// How many of a game's latest messages its feed keeps, so that a
+// subscriber that reconnects can catch up on what it missed.
+const feedBacklog = 256
+
+// How often an idle stream is sent a comment, so that proxies don't
+// close it.
+const feedHeartbeat = 15 * time.Second
+
+// How long a poll waits for a message before it responds without one.
+const pollTimeout = 25 * time.Second
+
+// The default of how long a player that isn't connected by websocket
+// can go without guessing or following the feed before they're
+// benched. See [Game.IdleTimeout].
+const DefaultIdleTimeout = time.Minute
+
+// A message that was published to the game, which is the same JSON
+// as what the websocket sends, with its place in the game's feed.
+// A message that's only for one player is sent `To` them alone.
+type FeedMessage struct {
+	ID      int             `json:"id"`
+	Type    string          `json:"-"`
+	To      string          `json:"-"`
+	Message json.RawMessage `json:"message"`
+}
+
+// Whether the message is for the subscriber, who is the player
+// `name`, or else a display or the host.
+func (m FeedMessage) isFor(name string) bool {
+	return m.To == "" || m.To == name
+}
+
+// Everything that's published to a game's displays (which is what
+// its players are sent, and then some), numbered so that it can be
+// followed over HTTP instead of the websocket, either as a stream of
+// Server-Sent Events at `/events` or by polling `/events/poll`. See
+// [SocketServer.EventsHandler].
+//
+// A subscriber that can't keep up is dropped, and picks up where it
+// left off when it reconnects.
+//
+// A player that isn't connected by websocket is sent the messages
+// that are only for them (see [SocketServer.tell]) in the feed, and
+// they follow it with their secret to get them.
+type Feed struct {
+	mu          sync.Mutex
+	last        int
+	backlog     []FeedMessage
+	subscribers map[chan FeedMessage]string
+}
+
+func (f *Feed) publish(msgType string, b []byte) {
+	f.publishTo("", msgType, b)
+}
+
+// Publishes a message that only the player `to` is sent, or everyone
+// if it's empty.
+func (f *Feed) publishTo(to, msgType string, b []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.last++
+	msg := FeedMessage{
+		ID:      f.last,
+		Type:    msgType,
+		To:      to,
+		Message: b,
+	}
+	f.backlog = append(f.backlog, msg)
+	if len(f.backlog) > feedBacklog {
+		f.backlog = f.backlog[len(f.backlog)-feedBacklog:]
+	}
+	for ch, name := range f.subscribers {
+		if !msg.isFor(name) {
+			continue
+		}
+		select {
+		case ch <- msg:
+		default:
+			delete(f.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribes to the messages that come after the message `after`,
+// and returns those that have already been published. The subscriber
+// is the player `name`, or else a display or the host. It isn't ok
+// when the subscriber has missed more than the backlog, or `after`
+// is from before the server was restarted, in which case the
+// subscriber must start over from the game's snapshot.
+func (f *Feed) subscribe(after int, name string) (ch chan FeedMessage, missed []FeedMessage, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.subscribers == nil {
+		f.subscribers = make(map[chan FeedMessage]string)
+	}
+	ch = make(chan FeedMessage, feedBacklog)
+	f.subscribers[ch] = name
+	if after > f.last || len(f.backlog) > 0 && after < f.backlog[0].ID-1 {
+		return ch, nil, false
+	}
+	for _, msg := range f.backlog {
+		if msg.ID > after && msg.isFor(name) {
+			missed = append(missed, msg)
+		}
+	}
+	return ch, missed, true
+}
+
+// Returns the player that was subscribed, if any.
+func (f *Feed) unsubscribe(ch chan FeedMessage) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, ok := f.subscribers[ch]
+	if ok {
+		delete(f.subscribers, ch)
+		close(ch)
+	}
+	return name
+}
+
+// Whether the player `name` is following the feed right now.
+func (f *Feed) following(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, subscriber := range f.subscribers {
+		if subscriber == name {
+			return true
+		}
+	}
+	return false
+}
+
+// The id of the latest message.
+func (f *Feed) Last() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.last
+}
+
+// The feed is read-only, so it can be followed with either the API key
+// or the display key, which is given in the `X-TRIVIA-APIKEY` header or
+// the `token` query parameter (since a browser's `EventSource` can't
+// send headers).
+func (s *SocketServer) feedGame(r *http.Request) (*Game, error) {
+	key := r.Header.Get("X-TRIVIA-APIKEY")
+	if key == "" {
+		key = r.URL.Query().Get("token")
+	}
+	if game, err := s.GetGameByDisplayKey(key); err == nil {
+		return game, nil
+	}
+	return s.GetGame(key)
+}
+
+// The player that's following the feed, who gives their secret in
+// the `X-TRIVIA-SECRET` header or the `secret` query parameter. It's
+// empty for a display or the host, who don't give one.
+// The caller must hold the game's lock.
+func (g *Game) feedPlayer(r *http.Request) (string, error) {
+	secret := r.Header.Get("X-TRIVIA-SECRET")
+	if secret == "" {
+		secret = r.URL.Query().Get("secret")
+	}
+	if secret == "" {
+		return "", nil
+	}
+	for _, pool := range []GamePlayers{g.Players, g.Benched} {
+		for _, player := range pool {
+			if player.hasSecret(secret) {
+				return player.Name, nil
+			}
+		}
+	}
+	return "", statusErrorf(http.StatusUnauthorized, "Bad secret")
+}
+
+// Where a subscriber left off, which is the `Last-Event-ID` header
+// that a browser sends when it reconnects, or else the `after` query
+// parameter. It's zero when the subscriber is starting out.
+func lastEventID(r *http.Request) (int, error) {
+	id := r.Header.Get("Last-Event-ID")
+	if id == "" {
+		id = r.URL.Query().Get("after")
+	}
+	if id == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(strings.TrimSpace(id))
+}
+
+// Subscribes to the game's feed, and returns what the subscriber
+// needs to catch up: the game's snapshot, if it's starting over,
+// followed by the messages that it missed. The snapshot is sent as a
+// `display` event, like a display gets when it connects.
+func (s *SocketServer) subscribeFeed(game *Game, r *http.Request, after int) (chan FeedMessage, []FeedMessage, error) {
+	game.mu.Lock()
+	defer game.mu.Unlock()
+	name, err := game.feedPlayer(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	game.seen(name)
+	ch, missed, ok := game.Feed.subscribe(after, name)
+	if after > 0 && ok {
+		return ch, missed, nil
+	}
+	snapshot, err := game.Snapshot()
+	if err != nil {
+		game.Feed.unsubscribe(ch)
+		return nil, nil, err
+	}
+	b, err := json.Marshal(ServerMessage{
+		Type: "display",
+		Data: snapshot,
+	})
+	if err != nil {
+		game.Feed.unsubscribe(ch)
+		return nil, nil, err
+	}
+	// The game's lock is held, so nothing has been published since.
+	return ch, []FeedMessage{{
+		ID:      game.Feed.Last(),
+		Type:    "display",
+		Message: b,
+	}}, nil
+}
+
+// Unsubscribes from the game's feed, which a player was following
+// until now.
+func (s *SocketServer) unsubscribeFeed(game *Game, ch chan FeedMessage) {
+	if name := game.Feed.unsubscribe(ch); name != "" {
+		game.mu.Lock()
+		game.seen(name)
+		game.mu.Unlock()
+	}
+}
+
+// The caller must hold the game's lock.
+func (g *Game) seen(name string) {
+	if _, player := has(g.Players, name); player != nil {
+		player.LastSeen = time.Now()
+	}
+}
+
+// Benches a player that isn't connected by websocket once they've
+// been idle for `d`, unless they guess or follow the feed by then.
+// The caller must hold the game's lock.
+func (s *SocketServer) watchIdle(game *Game, player *Player, d time.Duration) {
+	if player.idle != nil {
+		player.idle.Stop()
+	}
+	player.idle = newGameTimer(d, func() {
+		game.mu.Lock()
+		defer game.mu.Unlock()
+		player.idle = nil
+		if n, _ := has(game.Players, player); n == -1 || player.Socket != nil {
+			return
+		}
+		if game.Feed.following(player.Name) {
+			s.watchIdle(game, player, game.IdleTimeout)
+			return
+		}
+		if idle := time.Since(player.LastSeen); idle < game.IdleTimeout {
+			s.watchIdle(game, player, game.IdleTimeout-idle)
+			return
+		}
+		fmt.Printf("%s has been idle for %v\n", player.Name, game.IdleTimeout)
+		if err := s.leave(game, player, "idle"); err != nil {
+			fmt.Println(err)
+		}
+	})
+}
+
+func writeEvent(w http.ResponseWriter, msg FeedMessage) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", msg.ID, msg.Type, msg.Message)
+	return err
+}
+
+// Streams the game's feed as Server-Sent Events, for a consumer that
+// only watches the game, or one whose proxy won't pass the websocket.
+// Each event is named for the message's type, and its data is the
+// same JSON that the websocket sends. A browser's `EventSource`
+// reconnects on its own, and resumes from the last event it got.
+// See [Feed].
+//
+//	const events = new EventSource(`/events?token=${displayKey}`);
+//	events.addEventListener("reveal", e => show(JSON.parse(e.data)));
+func (s *SocketServer) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	game, err := s.feedGame(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	after, err := lastEventID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming isn't supported", http.StatusInternalServerError)
+		return
+	}
+	ch, missed, err := s.subscribeFeed(game, r, after)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+	defer s.unsubscribeFeed(game, ch)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Stops nginx from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	for _, msg := range missed {
+		if err := writeEvent(w, msg); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+	heartbeat := time.NewTicker(feedHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case msg, ok := <-ch:
+			// The stream fell behind, so it's closed, and the
+			// client reconnects from the last event it got.
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, msg); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// What a poll of the feed responds with. The next poll should ask for
+// the messages after `Last`.
+type Poll struct {
+	Last     int           `json:"last"`
+	Messages []FeedMessage `json:"messages"`
+}
+
+// Long-polls the game's feed, for a client that can use neither the
+// websocket nor Server-Sent Events. It responds with the messages
+// after `?after=` as soon as there are any, or with none after 25
+// seconds. A client that's starting out (or has fallen too far behind)
+// is sent the game's snapshot first. See [SocketServer.EventsHandler].
+func (s *SocketServer) PollHandler(w http.ResponseWriter, r *http.Request) {
+	game, err := s.feedGame(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	after, err := lastEventID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ch, messages, err := s.subscribeFeed(game, r, after)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+	defer s.unsubscribeFeed(game, ch)
+	if len(messages) == 0 {
+		timeout := time.NewTimer(pollTimeout)
+		defer timeout.Stop()
+		select {
+		case msg, ok := <-ch:
+			if ok {
+				messages = append(messages, msg)
+			}
+		case <-timeout.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	// Anything else that was published along with it.
+	for len(ch) > 0 {
+		messages = append(messages, <-ch)
+	}
+	poll := Poll{
+		Last:     after,
+		Messages: make([]FeedMessage, 0),
+	}
+	poll.Messages = append(poll.Messages, messages...)
+	if len(messages) > 0 {
+		poll.Last = messages[len(messages)-1].ID
+	}
+	writeJSON(w, http.StatusOK, poll)
+}
+
+// A guess over HTTP, for a player whose websocket doesn't get through.
+// The player is identified by their name and the `Secret` that they
+// were sent in a `secret` message when they logged in, which, unlike
+// their name, is never published.
+type GuessRequest struct {
+	Name   string `json:"name"`
+	Secret string `json:"secret"`
+	Guess  any    `json:"guess"`
+}
+
+// The secret that a player who has been guessing over HTTP gives in
+// the data of their websocket `login` message, to take their place
+// back.
+func loginSecret(data any) string {
+	if data, ok := data.(map[string]any); ok {
+		secret, _ := data["secret"].(string)
+		return secret
+	}
+	return ""
+}
+
+// Records a player's guess, like the websocket's `guess` message does,
+// and responds with the notice that the player would otherwise be
+// sent. A player that was benched when their websocket was dropped is
+// brought back, and follows the game's feed with their secret from
+// then on (see [SocketServer.PollHandler]), which is where they're
+// sent the messages that are only for them. They're benched again if
+// they go idle (see [SocketServer.watchIdle]), and they can log back
+// in over the websocket with their secret. Only a player that has
+// logged in can guess, and a player that was kicked can't come back
+// this way.
+func (s *SocketServer) GuessHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "a guess must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req GuessRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Malformed request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	s.control(w, r, func(game *Game) error {
+		player, benched := game.HasPlayer(req.Name)
+		if player == nil || !player.hasSecret(req.Secret) {
+			return statusErrorf(http.StatusNotFound, "Player not found.")
+		}
+		if benched {
+			if player.BenchReason == "kicked" {
+				return statusErrorf(http.StatusForbidden, "You've been kicked from the game")
+			}
+			if game.State == StateFinished {
+				return statusErrorf(http.StatusConflict, "The game is over")
+			}
+			captain := game.captainOf(player)
+			game.Unbench(player)
+			player.Socket = nil
+			s.watchIdle(game, player, game.IdleTimeout)
+			s.firePlayer(game, webhook.PlayerJoined, player, "")
+			err := s.Publish(game, ServerMessage{
+				Type: "player_add",
+				Data: game.Players,
+			})
+			if err != nil {
+				return err
+			}
+			if err := s.publishCaptain(game, player, captain); err != nil {
+				return err
+			}
+		}
+		player.LastSeen = time.Now()
+		notice, err := s.Guess(game, player, req.Guess)
+		if err != nil {
+			return withStatus(http.StatusConflict, err)
+		}
+		fmt.Fprintln(w, notice)
+		return nil
+	})
+}
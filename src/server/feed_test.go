@@ -0,0 +1,65 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+// A player that's guessing over HTTP is benched once they go idle, so
+// that the question isn't held up waiting for them.
+func TestWatchIdle(t *testing.T) {
+	tests := []struct {
+		name    string
+		follow  bool
+		benched bool
+	}{
+		{"idle", false, true},
+		{"following the feed", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, g := newTestGame(t, func(g *Game) {
+				g.IdleTimeout = 20 * time.Millisecond
+			})
+			join(t, g, "alice", "bob")
+			mustDo(t, s.SetState(g, StateRunning))
+			ask(t, s, g, "What's the capital of France?|30|Paris")
+			bob := player(t, g, "bob")
+			mustDo(t, g.Bench(bob, "left"))
+			// Bob comes back over HTTP, like GuessHandler brings him back.
+			mustDo(t, g.Unbench(bob))
+			bob.LastSeen = time.Now()
+			if tt.follow {
+				ch, _, _ := g.Feed.subscribe(0, "bob")
+				defer s.unsubscribeFeed(g, ch)
+			}
+			g.mu.Lock()
+			s.watchIdle(g, bob, g.IdleTimeout)
+			guess(t, s, g, "alice", "Paris")
+			g.mu.Unlock()
+
+			time.Sleep(5 * g.IdleTimeout)
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			defer func() {
+				if bob.idle != nil {
+					bob.idle.Stop()
+				}
+			}()
+			if _, benched := g.HasPlayer("bob"); benched != tt.benched {
+				t.Fatalf("bob is benched = %v, want %v", benched, tt.benched)
+			}
+			if tt.benched {
+				if bob.BenchReason != "idle" {
+					t.Errorf("bob was benched for %q, want %q", bob.BenchReason, "idle")
+				}
+				// Alice was the last one left to answer.
+				if g.IsOpen() {
+					t.Error("the question is still open")
+				}
+			} else if !g.IsOpen() {
+				t.Error("the question was closed without bob's answer")
+			}
+		})
+	}
+}
@@ -1,10 +1,13 @@
 package server
 
 import (
+	"crypto/subtle"
 	"errors"
+	"fmt"
 	"math"
-	"sort"
+	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/btoll/trivial/src/middleware"
@@ -23,16 +26,37 @@ type GamePlayers []*Player
 // browser before sending a request so it could be unreliable.
 //
 // The `UUID` is set by the client (browser) and sent
-// as part of the websocket URL. It's not currently used.
+// as part of the websocket URL.
 //
 //	const socketURL = `{{ . }}?uuid=${getUUID()}`;
 //	socket = new WebSocket(socketURL);
+//
+// The `Secret` is given to the player when they log in, and only
+// to them, so that they can guess over HTTP when their websocket
+// is dropped (see [SocketServer.GuessHandler]). Neither is ever
+// sent with the list of players.
+//
+// A benched player's `BenchReason` is why they were benched (see
+// [PlayerBenched]), and it's empty while they're in the game.
+//
+// A player that isn't connected by websocket was `LastSeen` when they
+// last guessed or followed the feed, and is benched once they've been
+// idle for too long (see [SocketServer.watchIdle]).
 type Player struct {
-	Location string          `json:"location,omitempty"`
-	Name     string          `json:"name,omitempty"`
-	UUID     string          `json:"uuid,omitempty"`
-	Score    int             `json:"score"`
-	Socket   *websocket.Conn `json:"conn,omitempty"`
+	Location    string          `json:"location,omitempty"`
+	Name        string          `json:"name,omitempty"`
+	UUID        string          `json:"-"`
+	Secret      string          `json:"-"`
+	BenchReason string          `json:"-"`
+	Score       int             `json:"score"`
+	Team        string          `json:"team,omitempty"`
+	Socket      *websocket.Conn `json:"-"`
+	LastSeen    time.Time       `json:"-"`
+	idle        *gameTimer
+}
+
+func (p *Player) hasSecret(secret string) bool {
+	return secret != "" && subtle.ConstantTimeCompare([]byte(p.Secret), []byte(secret)) == 1
 }
 
 type Scoreboard []*PlayerScore
@@ -51,27 +75,204 @@ func (s Scoreboard) Swap(i, j int) {
 
 // This is currently for an admin to get a quick view
 // of the game state.
+//
+// When the game is split into rounds, `Rounds` are the points
+// the player earned in each round.
+//
+// The `Change` in rank is since the last question.
+// See [Game.History].
+//
+// The number of `Correct` answers, the total `AnswerTime` (in
+// seconds) and the time of the `LastCorrect` answer are what the
+// tie-breakers compare. See [TieCorrect].
 type PlayerScore struct {
-	Name  string
-	Score int
+	Name        string    `json:"name"`
+	Team        string    `json:"team,omitempty"`
+	Score       int       `json:"score"`
+	Rank        int       `json:"rank"`
+	Change      int       `json:"change"`
+	Correct     int       `json:"correct"`
+	AnswerTime  float64   `json:"answerTime"`
+	LastCorrect time.Time `json:"-"`
+	Rounds      []int     `json:"rounds,omitempty"`
 }
 
 // `Weight` is the amount of points awarded for a
 // correct answer.
+//
+// The `Order` of an ordering question is never marshaled to
+// the browser, since it would give away the answer.
+// See [parseQuestion].
+//
+// Likewise, the `Media` path is only known to the server, and
+// the browser fetches the file by its `MediaID`.
+// See [SocketServer.MediaHandler].
+//
+// A `Wager` question is worth each player's wager rather than its
+// `Weight`, and it isn't shown until the wagers have been placed.
+// See [PhaseWager].
+//
+// Only the player holding the buzz may answer a `Buzzer` question.
+// See [BuzzQueue].
+//
+// Only the `Contenders` may answer the sudden-death question.
+// See [Game.SuddenDeath].
 type CurrentQuestion struct {
-	Question  string   `json:"question,omitempty"`
-	Answer    any      `json:"answer,omitempty"`
-	Choices   []string `json:"choices,omitempty"`
-	Weight    int      `json:"weight,omitempty"`
-	Responses int      `json:"responses,omitempty"`
+	Number     int                  `json:"number,omitempty"`
+	Round      int                  `json:"round,omitempty"`
+	Category   string               `json:"category,omitempty"`
+	Question   string               `json:"question,omitempty"`
+	Kind       string               `json:"kind,omitempty"`
+	Media      string               `json:"-"`
+	MediaID    string               `json:"mediaId,omitempty"`
+	MediaType  string               `json:"mediaType,omitempty"`
+	Answer     any                  `json:"answer,omitempty"`
+	Choices    []string             `json:"choices,omitempty"`
+	Order      []int                `json:"-"`
+	Scoring    string               `json:"scoring,omitempty"`
+	Weight     int                  `json:"weight,omitempty"`
+	Policy     string               `json:"policy,omitempty"`
+	TimeLimit  int                  `json:"timeLimit,omitempty"`
+	Wager      bool                 `json:"wager,omitempty"`
+	Phase      string               `json:"-"`
+	Wagers     map[string]int       `json:"-"`
+	Buzzer     bool                 `json:"buzzer,omitempty"`
+	Buzzes     *BuzzQueue           `json:"-"`
+	Contenders []string             `json:"contenders,omitempty"`
+	Asked      time.Time            `json:"-"`
+	Deadline   time.Time            `json:"-"`
+	Closed     bool                 `json:"-"`
+	Responses  map[string]*Response `json:"-"`
+	Stats      *QuestionStats       `json:"-"`
+}
+
+// A player's answer to the current question, keyed by the player's
+// name in [CurrentQuestion]. The `Points` are what the guess earned,
+// and they're held until the question is closed.
+// See [Game.Close].
+type Response struct {
+	Guess    any       `json:"guess"`
+	Points   int       `json:"points"`
+	Correct  bool      `json:"correct"`
+	Received time.Time `json:"received"`
+}
+
+// By default, a player only gets one guess per question. The host
+// can instead allow the players to change their answer until the
+// question's deadline (if there is one).
+const (
+	AnswerOnce   = "once"
+	AnswerChange = "change"
+)
+
+// A player's outcome for a question, which is revealed
+// to everyone when the question is closed.
+type Result struct {
+	Name    string `json:"name"`
+	Guess   string `json:"guess"`
+	Correct bool   `json:"correct"`
+	Points  int    `json:"points"`
+}
+
+// Everything that is published when a question is closed.
+// The team results and scoreboard are only given in teams mode.
+// See [SocketServer.Reveal].
+type Reveal struct {
+	Number         int            `json:"number"`
+	Question       string         `json:"question"`
+	Answer         string         `json:"answer"`
+	Results        []Result       `json:"results"`
+	TeamResults    []TeamResult   `json:"teamResults,omitempty"`
+	Distribution   map[string]int `json:"distribution"`
+	Scoreboard     Scoreboard     `json:"scoreboard"`
+	TeamScoreboard TeamScoreboard `json:"teamScoreboard,omitempty"`
 }
 
+// `TimeLimit` is how long the players have to answer
+// a question. A zero value means there is no limit.
+//
+// The game is played in `Teams` when it has a `TeamPolicy`.
+// See [TeamCaptain], [TeamMajority] and [TeamFirst].
+//
+// The game can optionally be split into `Rounds`.
+// See [Round].
+//
+// The buzzers of a buzzer round are armed after `BuzzerDelay`,
+// and a player that buzzes too early is locked out for
+// `BuzzerLockout`. The holder of the buzz has `BuzzerWindow`
+// to answer. See [BuzzQueue].
+//
+// A player that isn't connected by websocket is benched once they've
+// been idle for `IdleTimeout`, so that the game doesn't wait on them.
+// It's long enough for a player that polls the feed to poll again.
+// See [SocketServer.watchIdle].
+//
+// The game can be projected for everyone to see by any number of
+// `Displays`, which aren't players. See [SocketServer.DisplayHandler].
+// The host can also follow the game, including every guess as it's
+// made, with any number of `Watchers`. See [SocketServer.connectWatcher].
+// Since the players log in with the API key, what would give away the
+// answers also needs the `HostKey`, which only the host is given.
+// Everything the displays are sent is also kept in the game's `Feed`,
+// which can be followed over HTTP. See [Feed].
+//
+// The `State` of a game is its place in the lifecycle.
+// See [StateLobby].
+//
+// The order of players with the same score is decided by the
+// `TieBreakers`. If players are still tied for first when the game
+// is finished, they play the `SuddenDeath` question (if there is one).
+// See [TieCorrect].
+//
+// The standings after every question are kept in `History`.
+//
+// Every question is kept in `Questions` once it has been closed,
+// along with its statistics, for the post-game report.
+//
+// Every host action that changes the scores or ejects players is
+// kept in the `Audit` log. See [AuditEntry].
+//
+// Every change to the game's state is an event, which is kept in
+// `Events` and written to its `Journal` (if it has one), so that the
+// game can be replayed. See [Game.Apply].
+//
+// Every websocket connection and HTTP request is handled in
+// its own goroutine, as is the timer that closes a question,
+// so they all must hold `mu` while they use the game.
 type Game struct {
-	Name    string
-	Players GamePlayers
-	Benched GamePlayers
-	Key     middleware.APIKey
+	Name          string
+	State         string
+	Players       GamePlayers
+	Benched       GamePlayers
+	Key           middleware.APIKey
+	DisplayKey    string
+	HostKey       string
+	Displays      []*websocket.Conn
+	Watchers      []*websocket.Conn
+	Feed          Feed
+	AnswerPolicy  string
+	TimeLimit     time.Duration
+	Teams         []*Team
+	TeamPolicy    string
+	Rounds        []*Round
+	BuzzerDelay   time.Duration
+	BuzzerLockout time.Duration
+	BuzzerWindow  time.Duration
+	IdleTimeout   time.Duration
+	Questions     []CurrentQuestion
+	History       []Standing
+	TieBreakers   []string
+	SuddenDeath   *CurrentQuestion
+	Audit         []AuditEntry
+	Events        []Event
+	Journal       *Journal
 	CurrentQuestion
+	mu     sync.Mutex
+	timer  *gameTimer
+	paused time.Time
+	// What [Game.Close] returns, which is decided as the question's
+	// event is applied.
+	revealed *Reveal
 }
 
 func has(pool GamePlayers, v any) (int, *Player) {
@@ -116,9 +317,17 @@ func toInt(b []byte) (int, error) {
 // Constructor.
 func NewGame(name string, tokenExpiration float64) *Game {
 	return &Game{
-		Name:    name,
-		Players: make(GamePlayers, 0),
-		Key:     middleware.GenerateKey(name, tokenExpiration),
+		Name:          name,
+		State:         StateLobby,
+		Players:       make(GamePlayers, 0),
+		Key:           middleware.GenerateKey(name, tokenExpiration),
+		DisplayKey:    newRandomID(),
+		HostKey:       newRandomID(),
+		AnswerPolicy:  AnswerOnce,
+		BuzzerDelay:   DefaultBuzzerDelay,
+		BuzzerLockout: DefaultBuzzerLockout,
+		BuzzerWindow:  DefaultBuzzerWindow,
+		IdleTimeout:   DefaultIdleTimeout,
 	}
 }
 
@@ -131,14 +340,106 @@ func NewGame(name string, tokenExpiration float64) *Game {
 // disconnect occurred.
 // Note that this is different from a player choosing to exit the game
 // by clicking exit or close (TODO).
-func (g *Game) Bench(p *Player) error {
+// The `reason` is recorded in the game's journal.
+func (g *Game) Bench(p *Player, reason string) error {
 	n, player := has(g.Players, p)
 	if n == -1 {
 		return errors.New("Player not found.")
 	}
-	g.Players = remove(g.Players, n)
-	g.Benched = append(g.Benched, player)
-	return nil
+	return g.emit(EventPlayerBenched, PlayerBenched{
+		Name:   player.Name,
+		Reason: reason,
+	})
+}
+
+// Closes the current question, adds the points of every response
+// to the players' scores and returns what is to be revealed.
+// A player that has since been benched still gets their points.
+func (g *Game) Close() (*Reveal, error) {
+	q := &g.CurrentQuestion
+	if !g.IsOpen() {
+		return nil, errors.New("There is no open question")
+	}
+	if g.timer != nil {
+		g.timer.Stop()
+		g.timer = nil
+	}
+	if q.Buzzes != nil {
+		q.Buzzes.stop()
+	}
+	if err := g.emit(EventQuestionClosed, QuestionClosed{Number: q.Number}); err != nil {
+		return nil, err
+	}
+	return g.revealed, nil
+}
+
+func (g *Game) close() {
+	q := &g.CurrentQuestion
+	q.Closed = true
+	if q.Contenders != nil {
+		q.awardClosest()
+	}
+	round := g.CurrentRound()
+	for name, response := range q.Responses {
+		if response.Points != 0 {
+			if player, _ := g.HasPlayer(name); player != nil {
+				player.Score += response.Points
+			}
+		}
+		if round != nil {
+			round.Subtotals[name] += response.Points
+		}
+	}
+	teamResults := g.scoreTeams()
+	q.Stats = q.Statistics()
+	g.Questions = append(g.Questions, *q)
+
+	g.revealed = &Reveal{
+		Number:       q.Number,
+		Question:     q.Question,
+		Answer:       q.CorrectAnswer(),
+		Results:      q.Results(),
+		TeamResults:  teamResults,
+		Distribution: q.Distribution(),
+		Scoreboard:   g.GetScoreboard(),
+	}
+	g.recordStanding(g.revealed.Scoreboard)
+	if g.TeamPolicy != "" {
+		g.revealed.TeamScoreboard = g.GetTeamScoreboard()
+	}
+}
+
+// Returns the statistics of a question by its number. The current
+// question has none until it's closed, since they give away its
+// answer.
+func (g *Game) GetQuestionStats(n int) (*QuestionStats, error) {
+	if g.IsOpen() && n == g.CurrentQuestion.Number {
+		return nil, statusErrorf(http.StatusConflict, "question %d is still open", n)
+	}
+	for _, q := range g.Questions {
+		if q.Number == n {
+			return q.Stats, nil
+		}
+	}
+	return nil, statusErrorf(http.StatusNotFound, "question %d not found", n)
+}
+
+// Every active player has answered the current question. Players
+// who have been benched aren't counted, and a player that answers
+// more than once is only counted once.
+func (g *Game) AllResponded() bool {
+	if len(g.Players) == 0 {
+		return false
+	}
+	for _, player := range g.Players {
+		if !g.CurrentQuestion.IsContender(player.Name) {
+			continue
+		}
+		if _, ok := g.CurrentQuestion.Responses[player.Name]; !ok {
+			return false
+		}
+	}
+	return true
 }
 
 // Called only when a new player logs in. It is legal for
@@ -187,10 +488,15 @@ func (g *Game) GetScoreboard() Scoreboard {
 	for i, player := range g.Players {
 		scoreboard[i] = &PlayerScore{
 			Name:  player.Name,
+			Team:  player.Team,
 			Score: player.Score,
 		}
+		for _, round := range g.Rounds {
+			scoreboard[i].Rounds = append(scoreboard[i].Rounds, round.Subtotals[player.Name])
+		}
 	}
-	sort.Sort(scoreboard)
+	g.sortScoreboard(scoreboard)
+	g.rank(scoreboard)
 	return scoreboard
 }
 
@@ -204,6 +510,85 @@ func (g *Game) HasPlayer(name string) (*Player, bool) {
 	return nil, false
 }
 
+// There is a question that hasn't been closed yet.
+func (g *Game) IsOpen() bool {
+	return g.CurrentQuestion.Responses != nil && !g.CurrentQuestion.Closed
+}
+
+// The current question should be closed once every active player
+// has answered. However, if the players can change their answers
+// until a deadline, then it stays open until the time is up.
+// A buzzer question is closed as soon as it's answered correctly.
+func (g *Game) ReadyToClose() bool {
+	if g.IsOpen() && g.CurrentQuestion.Buzzer {
+		return g.buzzerDone()
+	}
+	if !g.IsOpen() || !g.AllResponded() {
+		return false
+	}
+	return g.AnswerPolicy != AnswerChange || g.CurrentQuestion.Deadline.IsZero()
+}
+
+// Records a player's guess for the current question. A second guess
+// is rejected unless the game's policy allows the player to change
+// their answer, in which case it replaces the previous one.
+// The points aren't added to the player's score until the question
+// is closed.
+// In a buzzer round, only the holder of the buzz may answer, and
+// only a correct answer earns points.
+// See [AnswerOnce] and [AnswerChange].
+func (g *Game) Respond(player *Player, guess any) (*Response, error) {
+	q := &g.CurrentQuestion
+	if err := g.CheckRunning(); err != nil {
+		return nil, err
+	}
+	if !g.IsOpen() {
+		return nil, errors.New("There is no question to answer")
+	}
+	if q.Phase == PhaseWager {
+		return nil, errors.New("Place your wager first")
+	}
+	if q.Buzzer && !g.HasBuzz(player.Name) {
+		return nil, errors.New("Buzz in first")
+	}
+	if !q.IsContender(player.Name) {
+		return nil, errors.New("Only the players tied for first can answer")
+	}
+	_, answered := q.Responses[player.Name]
+	if answered && g.AnswerPolicy != AnswerChange {
+		return nil, errors.New("You have already answered this question")
+	}
+	if !q.Deadline.IsZero() && time.Now().After(q.Deadline) {
+		return nil, errors.New("Time is up")
+	}
+	err := g.emit(EventGuessSubmitted, GuessSubmitted{
+		Name:  player.Name,
+		Guess: guess,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return q.Responses[player.Name], nil
+}
+
+func (g *Game) respond(name string, guess any, received time.Time) {
+	q := &g.CurrentQuestion
+	points, correct := q.Evaluate(guess)
+	if q.Wager {
+		points = q.wagerPoints(name, correct)
+	} else if q.Buzzer && !correct {
+		points = 0
+	} else {
+		points = g.roundPoints(points)
+	}
+	q.Responses[name] = &Response{
+		Guess:    guess,
+		Points:   points,
+		Correct:  correct,
+		Received: received,
+	}
+}
+
 // If a player logs back in after accidentally killing
 // their browser session (at which point they are "benched"),
 // move their player state from the .Benched pool to
@@ -215,23 +600,93 @@ func (g *Game) Unbench(p *Player) error {
 	if n == -1 {
 		return errors.New("Player not found.")
 	}
-	g.Benched = remove(g.Benched, n)
+	return g.emit(EventPlayerRejoined, PlayerRejoined{Name: player.Name})
+}
+
+// Adds a new player to the game, on a team when the game is played
+// in teams. See [Game.JoinTeam].
+func (g *Game) Join(name, uuid, location, team string) (*Player, error) {
+	team, err := g.chooseTeam(team)
+	if err != nil {
+		return nil, err
+	}
+	err = g.emit(EventPlayerJoined, PlayerJoined{
+		Name:     name,
+		UUID:     uuid,
+		Secret:   newRandomID(),
+		Location: location,
+		Team:     team,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g.Players[len(g.Players)-1], nil
+}
+
+func (g *Game) join(d *PlayerJoined) {
+	player := &Player{
+		Location: d.Location,
+		Name:     d.Name,
+		UUID:     d.UUID,
+		Secret:   d.Secret,
+	}
+	if d.Team != "" {
+		g.JoinTeam(player, d.Team)
+	}
 	g.Players = append(g.Players, player)
-	return nil
 }
 
-// Called every time a player guesses correctly. Currently,
-// this happens immediately after a correct guess and so
-// every player will see the updated score.
-// This isn't optimal and should be changed to only update
-// after everyone has guessed (TODO).
-func (g *Game) UpdatePlayerScore(socket *websocket.Conn, points int) (int, error) {
-	for i, player := range g.Players {
-		if socket == player.Socket {
-			total := points + player.Score
-			g.Players[i].Score = total
-			return total, nil
+// Called by the host to adjust a player's score.
+// This function expects either a player name (string), which
+// also finds a benched player, or a player socket (*websocket.Conn).
+func (g *Game) UpdatePlayerScore(v any, points int) (int, error) {
+	var player *Player
+	switch vv := v.(type) {
+	case string:
+		player, _ = g.HasPlayer(vv)
+	case *websocket.Conn:
+		player, _ = g.GetPlayer(vv)
+	}
+	if player == nil {
+		return 0, errors.New("Player not found.")
+	}
+	err := g.emit(EventScoreAdjusted, ScoreAdjusted{
+		Player: player.Name,
+		Points: points,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return player.Score, nil
+}
+
+// Sets every player's and team's score back to zero.
+func (g *Game) ResetScores() error {
+	return g.emit(EventScoresReset, ScoresReset{})
+}
+
+func (g *Game) adjustScore(d *ScoreAdjusted) error {
+	if d.Team != "" {
+		team, err := g.GetTeam(d.Team)
+		if err != nil {
+			return err
 		}
+		team.Score += d.Points
+		return nil
+	}
+	player, _ := g.HasPlayer(d.Player)
+	if player == nil {
+		return fmt.Errorf("player `%s` not found", d.Player)
+	}
+	player.Score += d.Points
+	return nil
+}
+
+func (g *Game) resetScores() {
+	for _, player := range g.Players {
+		player.Score = 0
+	}
+	for _, team := range g.Teams {
+		team.Score = 0
 	}
-	return 0, errors.New("Player not found.")
 }
@@ -1,16 +1,19 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 
 	"github.com/btoll/trivial/src/middleware"
+	"github.com/btoll/trivial/src/webhook"
 	"golang.org/x/net/websocket"
 )
 
@@ -45,17 +48,16 @@ func (s *SocketServer) DefaultHandler(socket *websocket.Conn) {
 				// matching player.
 				player, game, err := s.GetPlayerBySocket(socket)
 				if err != nil {
-					fmt.Println("read error:", err)
+					if !s.disconnectDisplay(socket) && !s.disconnectWatcher(socket) {
+						fmt.Println("read error:", err)
+					}
 				} else {
 					fmt.Printf("%s just left the building\n", player.Name)
-					game.Bench(player)
-					err = s.Publish(game, ServerMessage{
-						Type: "player_delete",
-						Data: game.Players,
-					})
-					if err != nil {
+					game.mu.Lock()
+					if err := s.leave(game, player, "left"); err != nil {
 						log.Fatalln(err)
 					}
+					game.mu.Unlock()
 				}
 				break
 			}
@@ -71,6 +73,18 @@ func (s *SocketServer) DefaultHandler(socket *websocket.Conn) {
 		if err != nil {
 			log.Fatalln(err)
 		}
+		messagesReceived.Inc(clientMessageType(msg.Type))
+
+		// A display has its own key and is never a player.
+		if msg.Type == "display" {
+			s.connectDisplay(socket, msg.Token)
+			continue
+		}
+		// Nor is the host, who watches with the host key.
+		if msg.Type == "watch" {
+			s.connectWatcher(socket, msg.Token)
+			continue
+		}
 
 		// `getGame` will verify the **equality** of the token
 		// **not** if it has expired.
@@ -87,14 +101,20 @@ func (s *SocketServer) DefaultHandler(socket *websocket.Conn) {
 				log.Fatalln("marshall error:", err)
 			}
 		} else {
+			game.mu.Lock()
 			switch msg.Type {
 			case "login":
 				username := strings.TrimSpace(msg.Username)
+				if !s.claimProfile(socket, username, msg.Data) {
+					break
+				}
 				player, benched := game.HasPlayer(msg.Username)
 				if player != nil {
 					if benched {
+						captain := game.captainOf(player)
 						game.Unbench(player)
 						player.Socket = socket
+						s.firePlayer(game, webhook.PlayerJoined, player, "")
 						err = s.Publish(game, ServerMessage{
 							Type: "player_add",
 							Data: game.Players,
@@ -102,6 +122,45 @@ func (s *SocketServer) DefaultHandler(socket *websocket.Conn) {
 						if err != nil {
 							log.Fatalln(err)
 						}
+						if err := s.publishCaptain(game, player, captain); err != nil {
+							log.Fatalln(err)
+						}
+						err = s.Message(socket, ServerMessage{
+							Type: "state",
+							Data: game.GetState(),
+						})
+						if err != nil {
+							log.Fatalln(err)
+						}
+						err = s.Message(socket, ServerMessage{
+							Type: "secret",
+							Data: player.Secret,
+						})
+						if err != nil {
+							log.Fatalln(err)
+						}
+					} else if player.Socket == nil && player.hasSecret(loginSecret(msg.Data)) {
+						// A player that has been guessing over HTTP is
+						// back on the websocket.
+						player.Socket = socket
+						if player.idle != nil {
+							player.idle.Stop()
+							player.idle = nil
+						}
+						err = s.Message(socket, ServerMessage{
+							Type: "state",
+							Data: game.GetState(),
+						})
+						if err != nil {
+							log.Fatalln(err)
+						}
+						err = s.Message(socket, ServerMessage{
+							Type: "secret",
+							Data: player.Secret,
+						})
+						if err != nil {
+							log.Fatalln(err)
+						}
 					} else {
 						err = s.Message(socket, ServerMessage{
 							Type: "error",
@@ -122,118 +181,123 @@ func (s *SocketServer) DefaultHandler(socket *websocket.Conn) {
 						if err != nil {
 							log.Fatalln(err)
 						}
+					} else if game.State == StateFinished {
+						err = s.Message(socket, ServerMessage{
+							Type: "error",
+							Data: "The game is over",
+						})
+						if err != nil {
+							log.Fatalln(err)
+						}
 					} else {
 						parsedUrl, err := url.Parse(fmt.Sprintf("%s", location))
 						if err != nil {
 							fmt.Println("url.Parse error:", err)
 						}
 						uuid := strings.Split(parsedUrl.RawQuery, "=")
-						newPlayer := &Player{
-							Location: fmt.Sprintf("%s", origin),
-							Name:     username,
-							UUID:     uuid[1],
-							Score:    0,
-							Socket:   socket,
+						// In teams mode, the player either chose a team when
+						// logging in or is assigned to one.
+						var team string
+						if data, ok := msg.Data.(map[string]any); ok {
+							team, _ = data["team"].(string)
 						}
-						game.Players = append(game.Players, newPlayer)
-						err = s.Publish(game, ServerMessage{
-							Type: "player_add",
-							Data: game.Players,
-						})
+						newPlayer, err := game.Join(username, uuid[1], fmt.Sprintf("%s", origin), team)
 						if err != nil {
-							log.Fatalln(err)
+							err = s.Message(socket, ServerMessage{
+								Type: "error",
+								Data: err.Error(),
+							})
+							if err != nil {
+								log.Fatalln(err)
+							}
+						} else {
+							newPlayer.Socket = socket
+							s.firePlayer(game, webhook.PlayerJoined, newPlayer, "")
+							err = s.Publish(game, ServerMessage{
+								Type: "player_add",
+								Data: game.Players,
+							})
+							if err != nil {
+								log.Fatalln(err)
+							}
+							// Let the player know whether they're in the lobby.
+							err = s.Message(socket, ServerMessage{
+								Type: "state",
+								Data: game.GetState(),
+							})
+							if err != nil {
+								log.Fatalln(err)
+							}
+							// Only the player is told their secret, which
+							// they guess with if their websocket is dropped.
+							err = s.Message(socket, ServerMessage{
+								Type: "secret",
+								Data: newPlayer.Secret,
+							})
+							if err != nil {
+								log.Fatalln(err)
+							}
 						}
 					}
 				}
-			case "guess":
-				// Note that we're also doing this above.
-				// Should this be done for every received message?
+			case "wager":
 				player, err := game.GetPlayer(socket)
 				if err != nil {
 					fmt.Println(err)
 				} else {
-					var correct string
-					var playerGuess string
-					res := true
-
-					switch vv := msg.Data.(type) {
-					// Note that if the response type is a float64, then it's a bitmap.
-					case float64:
-						answer := game.CurrentQuestion.Answer.(uint16)
-						// Compensate for the bit that we set for the multi-answer
-						// multiple choice question.
-						if answer>>15 == 1 {
-							res = float64(answer) == (1<<15)+vv
-						} else {
-							res = float64(answer) == vv
-						}
-					case string:
-						res = game.CurrentQuestion.Answer == vv
+					notice := fmt.Sprintf("Your wager of %v has been recorded", msg.Data)
+					amount, ok := msg.Data.(float64)
+					if !ok || amount != math.Trunc(amount) {
+						notice = "Your wager must be a whole number"
+					} else if err := game.PlaceWager(player, int(amount)); err != nil {
+						notice = err.Error()
 					}
-
-					// Increment the field that we'll use to determine when every player
-					// has responded.  At that point, we'll update the scoreboard.
-					game.CurrentQuestion.Responses += 1
-
-					// Message the player individually if the answer was correct (or not).
-					err := s.Message(socket, ServerMessage{
-						Type: "player_message",
-						Data: res,
+					err = s.Message(socket, ServerMessage{
+						Type: "notify_player",
+						Data: notice,
 					})
 					if err != nil {
 						log.Fatalln(err)
 					}
-					t := getItemFromLog(game.CurrentQuestion.Choices, game.CurrentQuestion.Answer.(uint16))
-					correct = strings.Join(t, ",")
-					//						f := msg.Data.(float64)
-					fl := msg.Data.(float64)
-					f := getItemFromLog(game.CurrentQuestion.Choices, uint16(fl))
-					playerGuess = strings.Join(f, ",")
-
-					if !res {
-						err := s.Message(socket, ServerMessage{
-							Type: "notify_player",
-							Data: fmt.Sprintf("The correct answer is %s", correct),
-						})
-						if err != nil {
-							log.Fatalln(err)
+					// Once everyone has wagered, show the question.
+					if game.ReadyToShow() {
+						if err := s.Show(game); err != nil {
+							fmt.Println(err)
 						}
 					}
-
-					// Log the player's result.
-					if res {
-						_, err := game.UpdatePlayerScore(socket, game.CurrentQuestion.Weight)
-						if err != nil {
-							log.Fatalln(err)
-						}
-						fmt.Printf("%s correctly guessed %s, %d current points\n",
-							player.Name,
-							correct,
-							player.Score)
-					} else {
-						fmt.Printf("%s incorrectly guessed %s, %d current points\n",
-							player.Name,
-							playerGuess,
-							player.Score)
+				}
+			case "buzz":
+				player, err := game.GetPlayer(socket)
+				if err != nil {
+					fmt.Println(err)
+				} else if err := s.Buzz(game, player); err != nil {
+					err = s.Message(socket, ServerMessage{
+						Type: "notify_player",
+						Data: err.Error(),
+					})
+					if err != nil {
+						log.Fatalln(err)
 					}
-
-					// If everyone has answered, update everyone by updating the scoreboard.
-					if len(game.Players) == game.CurrentQuestion.Responses {
-						err = s.Publish(game, ServerMessage{
-							Type: "update_scoreboard",
-							Data: game.Players,
+				}
+			case "guess":
+				// Note that we're also doing this above.
+				// Should this be done for every received message?
+				player, err := game.GetPlayer(socket)
+				if err != nil {
+					fmt.Println(err)
+				} else {
+					if _, err := s.Guess(game, player, msg.Data); err != nil {
+						err = s.Message(socket, ServerMessage{
+							Type: "notify_player",
+							Data: err.Error(),
 						})
 						if err != nil {
 							log.Fatalln(err)
 						}
-						b, err := json.Marshal(game.GetScoreboard())
-						if err != nil {
-							fmt.Println(err)
-						}
-						fmt.Println(string(b))
 					}
 				}
 			}
+			game.mu.Unlock()
 		}
 	}
 }
@@ -242,247 +306,398 @@ func (s *SocketServer) HealthHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// Kicks a player out of the game, or every player of a team
+// when given a `team` query parameter.
 func (s *SocketServer) KillHandler(w http.ResponseWriter, r *http.Request) {
-	parsedUrl, err := url.Parse(fmt.Sprintf("%s", r.URL))
-	if err != nil {
-		fmt.Println("url.Parse error:", err)
-	}
-	p := strings.Split(parsedUrl.RawQuery, "=")
-	apiKey := r.Context().Value("apiKey").(*middleware.APIKey)
-	game, err := s.GetGame(apiKey.Key)
+	s.hostControl(w, r, func(game *Game) error {
+		if r.URL.Query().Has("team") {
+			team, err := game.findTeam(r.URL.Query().Get("team"))
+			if err != nil {
+				return err
+			}
+			return s.KickPlayers(game, actorOf(r), game.GetTeamPlayers(team.Name))
+		}
+		name, err := queryName(r)
+		if err != nil {
+			return err
+		}
+		player, err := game.findPlayer(name)
+		if err != nil {
+			return err
+		}
+		return s.KickPlayers(game, actorOf(r), GamePlayers{player})
+	})
+}
+
+func (s *SocketServer) MessageHandler(w http.ResponseWriter, r *http.Request) {
+	b, err := io.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	player, err := game.GetPlayer(p[1])
+	s.control(w, r, func(game *Game) error {
+		name, err := queryName(r)
+		if err != nil {
+			return err
+		}
+		return s.MessagePlayer(game, name, string(b))
+	})
+}
+
+func (s *SocketServer) NotifyHandler(w http.ResponseWriter, r *http.Request) {
+	b, err := io.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	err = s.Message(player.Socket, ServerMessage{
-		Type: "logout",
-		Data: "",
+	s.control(w, r, func(game *Game) error {
+		return s.NotifyAll(game, string(b))
 	})
+}
+
+// TODO: Use a CSV package for this?
+// See [parseQuestion] for the format of the request body.
+func (s *SocketServer) QueryHandler(w http.ResponseWriter, r *http.Request) {
+	b, err := io.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	err = game.Bench(player)
+	question, err := parseQuestion(fmt.Sprintf("%s", b))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	fmt.Println("killing player", player.Name)
-	err = s.Publish(game, ServerMessage{
-		Type: "update_scoreboard",
-		Data: game.Players,
+	s.control(w, r, func(game *Game) error {
+		if err := s.AskQuestion(game, question); err != nil {
+			return err
+		}
+		// Dump the current question to `stdout` (and later to a log).
+		b, err = json.MarshalIndent(game.CurrentQuestion, "", "    ")
+		if err != nil {
+			fmt.Println(err)
+		}
+		fmt.Println(string(b))
+		return nil
 	})
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
 }
 
-func (s *SocketServer) MessageHandler(w http.ResponseWriter, r *http.Request) {
-	parsedUrl, err := url.Parse(fmt.Sprintf("%s", r.URL))
-	if err != nil {
-		fmt.Println("url.Parse error:", err)
+// Starts the game, which lets the host ask the first question.
+func (s *SocketServer) StartHandler(w http.ResponseWriter, r *http.Request) {
+	s.changeState(w, r, StateLobby, StateRunning)
+}
+
+// Pauses the game, which freezes the timers of the current question.
+// Until the game is resumed, a guess is refused, and the player is
+// told that the game is paused. See [Game.CheckRunning].
+func (s *SocketServer) PauseHandler(w http.ResponseWriter, r *http.Request) {
+	s.changeState(w, r, "", StatePaused)
+}
+
+func (s *SocketServer) ResumeHandler(w http.ResponseWriter, r *http.Request) {
+	s.changeState(w, r, StatePaused, StateRunning)
+}
+
+// Ends the game and locks the scores. A question that is still
+// open is revealed first.
+func (s *SocketServer) FinishHandler(w http.ResponseWriter, r *http.Request) {
+	s.changeState(w, r, "", StateFinished)
+}
+
+// Moves the game to a new state, but only from the state `from`
+// when it's given.
+func (s *SocketServer) changeState(w http.ResponseWriter, r *http.Request, from, state string) {
+	s.control(w, r, func(game *Game) error {
+		return s.ChangeState(game, from, state)
+	})
+}
+
+// Ends the wagering of a wager question and shows the question,
+// even if not every player has placed a wager.
+func (s *SocketServer) ShowHandler(w http.ResponseWriter, r *http.Request) {
+	s.control(w, r, func(game *Game) error {
+		return s.ShowQuestion(game)
+	})
+}
+
+// Closes the current question and reveals the results to everyone,
+// even if not every player has answered.
+func (s *SocketServer) RevealHandler(w http.ResponseWriter, r *http.Request) {
+	s.control(w, r, func(game *Game) error {
+		return s.RevealQuestion(game)
+	})
+}
+
+// Responds with the statistics of a question at `/questions/{n}/stats`,
+// where `n` is the question's number (starting at one).
+func (s *SocketServer) QuestionStatsHandler(w http.ResponseWriter, r *http.Request) {
+	p := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(p) != 3 || p[2] != "stats" {
+		http.NotFound(w, r)
+		return
 	}
-	p := strings.Split(parsedUrl.RawQuery, "=")
-	apiKey := r.Context().Value("apiKey").(*middleware.APIKey)
-	game, err := s.GetGame(apiKey.Key)
+	n, err := strconv.Atoi(p[1])
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	player, err := game.GetPlayer(p[1])
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	game, ok := s.hostGame(w, r)
+	if !ok {
 		return
 	}
-	b, err := io.ReadAll(r.Body)
+	game.mu.Lock()
+	defer game.mu.Unlock()
+	stats, err := game.GetQuestionStats(n)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), statusOf(err))
 		return
 	}
-	err = s.Message(player.Socket, ServerMessage{
-		Type: "notify_player",
-		Data: string(b),
-	})
+	b, err := json.Marshal(stats)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		fmt.Println(err)
 	}
+	fmt.Fprintln(w, string(b))
 }
 
-func (s *SocketServer) NotifyHandler(w http.ResponseWriter, r *http.Request) {
-	b, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+// Responds with the post-game report in the format given by the
+// `format` query parameter: `json` (the default), `csv` or `html`.
+func (s *SocketServer) ReportHandler(w http.ResponseWriter, r *http.Request) {
+	game, ok := s.hostGame(w, r)
+	if !ok {
+		return
+	}
+	var err error
+	game.mu.Lock()
+	report := game.Report()
+	game.mu.Unlock()
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", ReportJSON:
+		w.Header().Set("Content-Type", "application/json")
+		err = report.WriteJSON(w)
+	case ReportCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", game.Name+".csv"))
+		err = report.WriteCSV(w)
+	case ReportHTML:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err = s.WriteReportHTML(w, report)
+	default:
+		http.Error(w, fmt.Sprintf("unknown format `%s`", format), http.StatusBadRequest)
 		return
 	}
-	apiKey := r.Context().Value("apiKey").(*middleware.APIKey)
-	game, err := s.GetGame(apiKey.Key)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	err = s.Publish(game, ServerMessage{
-		Type: "notify_all",
-		Data: fmt.Sprintf("%s", b),
+}
+
+func (s *SocketServer) ResetHandler(w http.ResponseWriter, r *http.Request) {
+	s.hostControl(w, r, func(game *Game) error {
+		return s.ResetScores(game, actorOf(r))
 	})
+}
+
+// Undoes the host's last score changes, as many as the request body
+// gives (one if it's empty). See [Game.UndoScores].
+func (s *SocketServer) UndoHandler(w http.ResponseWriter, r *http.Request) {
+	b, err := io.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	n := 1
+	if b = bytes.TrimSpace(b); len(b) > 0 {
+		n, err = toInt(b)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	s.hostControl(w, r, func(game *Game) error {
+		_, err := s.UndoScores(game, actorOf(r), n)
+		return err
+	})
 }
 
-// TODO: Use a CSV package for this?
-func (s *SocketServer) QueryHandler(w http.ResponseWriter, r *http.Request) {
+// Restores the scores to what they were before the question whose
+// number is the request body.
+func (s *SocketServer) RestoreHandler(w http.ResponseWriter, r *http.Request) {
 	b, err := io.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	l := strings.Split(fmt.Sprintf("%s", b), "|")
-	weight, err := strconv.Atoi(l[1])
+	number, err := toInt(bytes.TrimSpace(b))
 	if err != nil {
-		http.Error(w, fmt.Sprintf("%s", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	var choices []string
-	if len(l[0]) > 3 {
-		choices = l[3:]
-	}
+	s.hostControl(w, r, func(game *Game) error {
+		return s.RestoreScores(game, actorOf(r), number)
+	})
+}
 
-	apiKey := r.Context().Value("apiKey").(*middleware.APIKey)
-	game, err := s.GetGame(apiKey.Key)
+// Begins a new round. See [parseRound] for the format of the request body.
+func (s *SocketServer) RoundHandler(w http.ResponseWriter, r *http.Request) {
+	b, err := io.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	game.CurrentQuestion = CurrentQuestion{
-		Question: l[0],
-		Choices:  choices,
-		Weight:   weight,
-	}
-
-	if len(choices) > 0 {
-		answers := strings.Split(l[2], ",")
-		bitmap := makeBitmap(answers)
-		// If there is more than one answer than it is a multiple
-		// choice question with more than one right answer.
-		// As such, we need to encode this into the bitmap, so the
-		// UI can tell the difference between a multiple choice
-		// question with only one right answer and one with more
-		// than one.
-		// A bit value of `10000000 00000000` will instruct the UI
-		// to make checkbox options, while a bit value of
-		// `00000000 00000000` will instruct it to make radio options.
-		// weeeeeeeeeeeeeeeeeeeee
-		if len(answers) > 1 {
-			bitmap += 1 << 15
-		}
-		game.CurrentQuestion.Answer = bitmap
-	}
-
-	b, err = json.Marshal(game.CurrentQuestion)
+	round, err := parseRound(fmt.Sprintf("%s", b))
 	if err != nil {
-		fmt.Println(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	err = s.Publish(game, ServerMessage{
-		Type: "question",
-		Data: string(b),
+	s.control(w, r, func(game *Game) error {
+		if err := s.BeginRound(game, round); err != nil {
+			return err
+		}
+		round := game.CurrentRound()
+		fmt.Printf("started round %d `%s`\n", round.Number, round.Title)
+		return nil
 	})
+}
+
+// In teams mode, the teams are ranked instead of the players.
+func (s *SocketServer) ScoreboardHandler(w http.ResponseWriter, r *http.Request) {
+	apiKey := r.Context().Value("apiKey").(*middleware.APIKey)
+	game, err := s.GetGame(apiKey.Key)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	// Dump the current question to `stdout` (and later to a log).
-	b, err = json.MarshalIndent(game.CurrentQuestion, "", "    ")
+	game.mu.Lock()
+	defer game.mu.Unlock()
+	var scoreboard any = game.GetScoreboard()
+	if game.TeamPolicy != "" {
+		scoreboard = game.GetTeamScoreboard()
+	}
+	b, err := json.Marshal(scoreboard)
 	if err != nil {
 		fmt.Println(err)
 	}
-	fmt.Println(string(b))
+	fmt.Fprintln(w, string(b))
 }
 
-func (s *SocketServer) ResetHandler(w http.ResponseWriter, r *http.Request) {
+// Responds with every player's score and rank after each question,
+// for charting who led when.
+func (s *SocketServer) ScoreboardHistoryHandler(w http.ResponseWriter, r *http.Request) {
 	apiKey := r.Context().Value("apiKey").(*middleware.APIKey)
 	game, err := s.GetGame(apiKey.Key)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	for i := range game.Players {
-		game.Players[i].Score = 0
-	}
-	err = s.Publish(game, ServerMessage{
-		Type: "update_scoreboard",
-		Data: game.Players,
-	})
+	game.mu.Lock()
+	defer game.mu.Unlock()
+	b, err := json.Marshal(game.GetTimeline())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		fmt.Println(err)
 	}
+	fmt.Fprintln(w, string(b))
 }
 
-func (s *SocketServer) ScoreboardHandler(w http.ResponseWriter, r *http.Request) {
+// Responds with the season leaderboard, which ranks every player
+// with a profile by the games they've won and then by their points,
+// including their points so far in the current game.
+func (s *SocketServer) SeasonHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Profiles == nil {
+		http.Error(w, "Profiles aren't being kept", http.StatusNotFound)
+		return
+	}
 	apiKey := r.Context().Value("apiKey").(*middleware.APIKey)
 	game, err := s.GetGame(apiKey.Key)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	b, err := json.Marshal(game.GetScoreboard())
+	game.mu.Lock()
+	current := make(map[string]int)
+	// A finished game has already been added to the profiles.
+	if game.State != StateFinished {
+		for _, score := range game.GetScoreboard() {
+			current[score.Name] = score.Score
+		}
+	}
+	game.mu.Unlock()
+	b, err := json.Marshal(s.Profiles.Leaderboard(current))
 	if err != nil {
 		fmt.Println(err)
 	}
 	fmt.Fprintln(w, string(b))
 }
 
+// Adds the number of points in the request body (which can be negative)
+// to a player's score, or to a team's score when given a `team` query
+// parameter.
 func (s *SocketServer) UpdateScoreHandler(w http.ResponseWriter, r *http.Request) {
 	b, err := io.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	parsedUrl, err := url.Parse(fmt.Sprintf("%s", r.URL))
+	numToUpdate, err := toInt(bytes.TrimSpace(b))
 	if err != nil {
-		fmt.Println("url.Parse error:", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	p := strings.Split(parsedUrl.RawQuery, "=")
+	s.hostControl(w, r, func(game *Game) error {
+		if r.URL.Query().Has("team") {
+			_, err := s.AddTeamPoints(game, actorOf(r), r.URL.Query().Get("team"), numToUpdate)
+			return err
+		}
+		name, err := queryName(r)
+		if err != nil {
+			return err
+		}
+		_, err = s.AddPlayerPoints(game, actorOf(r), name, numToUpdate)
+		return err
+	})
+}
+
+// Runs one of the host's operations on the game, which reports an
+// error with its status. See [StatusError].
+func (s *SocketServer) control(w http.ResponseWriter, r *http.Request, f func(game *Game) error) {
 	apiKey := r.Context().Value("apiKey").(*middleware.APIKey)
 	game, err := s.GetGame(apiKey.Key)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	player, err := game.GetPlayer(p[1])
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	game.mu.Lock()
+	defer game.mu.Unlock()
+	if err := f(game); err != nil {
+		http.Error(w, err.Error(), statusOf(err))
 	}
-	// TODO: Should be able to update a player score by either socket or name.
-	// Here we're first having to get the player before updating it, instead
-	// of doing it in one operation.
-	// Look at `game.GetPlayer`'s implementation.
-	numToUpdate, err := toInt(b)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// Runs one of the host's operations that are audited, which, since
+// the players have the API key, need the host key as well. See
+// [SocketServer.hostGame].
+func (s *SocketServer) hostControl(w http.ResponseWriter, r *http.Request, f func(game *Game) error) {
+	game, ok := s.hostGame(w, r)
+	if !ok {
 		return
 	}
-	_, err = game.UpdatePlayerScore(player.Socket, numToUpdate)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	game.mu.Lock()
+	defer game.mu.Unlock()
+	if err := f(game); err != nil {
+		http.Error(w, err.Error(), statusOf(err))
 	}
-	err = s.Publish(game, ServerMessage{
-		Type: "update_scoreboard",
-		Data: game.Players,
-	})
+}
+
+// The original endpoints take a player's name as the value of their
+// only query parameter, e.g. `/kill?name=alice`.
+func queryName(r *http.Request) (string, error) {
+	_, name, found := strings.Cut(r.URL.RawQuery, "=")
+	if !found || name == "" {
+		return "", statusErrorf(http.StatusBadRequest, "A player's name is required, e.g. `?name=alice`")
+	}
+	name, err := url.QueryUnescape(name)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return "", withStatus(http.StatusBadRequest, err)
 	}
-	fmt.Printf("Added %d points to player `%s`.\n", numToUpdate, player.Name)
+	return name, nil
 }
@@ -0,0 +1,283 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// The status of each part of the server, from best to worst.
+const (
+	HealthOK   = "ok"
+	HealthWarn = "warn"
+	HealthFail = "fail"
+)
+
+// A certificate that expires sooner than this is a warning.
+const certWarning = 7 * 24 * time.Hour
+
+// What the load balancer sees. It doesn't need the API key, so it
+// doesn't give away anything about the questions or the keys.
+type Health struct {
+	// The worst status of its parts.
+	Status      string            `json:"status"`
+	Uptime      float64           `json:"uptime"`
+	Listener    ListenerHealth    `json:"listener"`
+	Certificate CertificateHealth `json:"certificate"`
+	Profiles    *ProfilesHealth   `json:"profiles,omitempty"`
+	Games       []GameHealth      `json:"games"`
+}
+
+type ListenerHealth struct {
+	Status  string     `json:"status"`
+	Address string     `json:"address,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+type CertificateHealth struct {
+	Status      string     `json:"status"`
+	Fingerprint string     `json:"fingerprint,omitempty"`
+	NotBefore   *time.Time `json:"notBefore,omitempty"`
+	NotAfter    *time.Time `json:"notAfter,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// Only reported when the players' profiles are kept.
+type ProfilesHealth struct {
+	Status   string `json:"status"`
+	Path     string `json:"path"`
+	Profiles int    `json:"profiles"`
+	Error    string `json:"error,omitempty"`
+}
+
+type GameHealth struct {
+	Name       string `json:"name"`
+	State      string `json:"state"`
+	Players    int    `json:"players"`
+	Benched    int    `json:"benched"`
+	Displays   int    `json:"displays"`
+	Watchers   int    `json:"watchers"`
+	Question   int    `json:"question"`
+	Open       bool   `json:"open"`
+	KeyExpired bool   `json:"keyExpired"`
+}
+
+func worse(a, b string) string {
+	rank := map[string]int{HealthOK: 0, HealthWarn: 1, HealthFail: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+func checkCertificate(path string, now time.Time) CertificateHealth {
+	cert, err := ReadCert(path)
+	if err != nil {
+		return CertificateHealth{Status: HealthFail, Error: err.Error()}
+	}
+	health := CertificateHealth{
+		Status:      HealthOK,
+		Fingerprint: Fingerprint(cert.Raw),
+		NotBefore:   &cert.NotBefore,
+		NotAfter:    &cert.NotAfter,
+	}
+	switch {
+	case now.Before(cert.NotBefore):
+		health.Status = HealthFail
+		health.Error = "the certificate isn't valid yet"
+	case now.After(cert.NotAfter):
+		health.Status = HealthFail
+		health.Error = "the certificate has expired"
+	case now.Add(certWarning).After(cert.NotAfter):
+		health.Status = HealthWarn
+		health.Error = "the certificate expires soon"
+	}
+	return health
+}
+
+// The caller must hold the game's lock.
+func (g *Game) health() GameHealth {
+	return GameHealth{
+		Name:       g.Name,
+		State:      g.State,
+		Players:    len(g.Players),
+		Benched:    len(g.Benched),
+		Displays:   len(g.Displays),
+		Watchers:   len(g.Watchers),
+		Question:   g.CurrentQuestion.Number,
+		Open:       g.IsOpen(),
+		KeyExpired: g.Key.Expired || time.Since(g.Key.TimeCreated).Seconds() > g.Key.Expiration,
+	}
+}
+
+// The server isn't ready until it's listening with a valid
+// certificate and has a game, and it isn't ready while the
+// profiles can't be saved.
+func (s *SocketServer) Health() Health {
+	now := time.Now()
+	health := Health{
+		Status:      HealthOK,
+		Listener:    ListenerHealth{Status: HealthFail},
+		Certificate: checkCertificate("cert.pem", now),
+		Games:       make([]GameHealth, 0, len(s.Games)),
+	}
+	if !s.listening.IsZero() {
+		health.Uptime = now.Sub(s.listening).Seconds()
+		health.Listener = ListenerHealth{
+			Status:  HealthOK,
+			Address: s.address,
+			Since:   &s.listening,
+		}
+	}
+	health.Status = worse(health.Listener.Status, health.Certificate.Status)
+	if s.Profiles != nil {
+		health.Profiles = &ProfilesHealth{
+			Status:   HealthOK,
+			Path:     s.Profiles.Path,
+			Profiles: s.Profiles.Len(),
+		}
+		if err := s.Profiles.Check(); err != nil {
+			health.Profiles.Status = HealthFail
+			health.Profiles.Error = err.Error()
+		}
+		health.Status = worse(health.Status, health.Profiles.Status)
+	}
+	for _, game := range s.Games {
+		game.mu.Lock()
+		health.Games = append(health.Games, game.health())
+		game.mu.Unlock()
+	}
+	sort.Slice(health.Games, func(i, j int) bool {
+		return health.Games[i].Name < health.Games[j].Name
+	})
+	if len(health.Games) == 0 {
+		health.Status = HealthFail
+	}
+	return health
+}
+
+// Always answers while the server is up, along with the health of
+// each of its parts. It doesn't need the API key.
+func (s *SocketServer) HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Health())
+}
+
+// Answers 503 Service Unavailable when any part of the server has
+// failed (see [SocketServer.Health]), so the load balancer stops
+// sending it players. It doesn't need the API key.
+func (s *SocketServer) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	health := s.Health()
+	status := http.StatusOK
+	if health.Status == HealthFail {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, health)
+}
+
+// Everything about a game except its keys and its sockets, for
+// debugging. The `Seq` is that of the game's last event.
+type DebugState struct {
+	Name          string               `json:"name"`
+	Seq           int                  `json:"seq"`
+	State         string               `json:"state"`
+	KeyCreated    time.Time            `json:"keyCreated"`
+	KeyExpiration float64              `json:"keyExpiration"`
+	KeyExpired    bool                 `json:"keyExpired"`
+	AnswerPolicy  string               `json:"answerPolicy"`
+	TimeLimit     float64              `json:"timeLimit"`
+	TeamPolicy    string               `json:"teamPolicy,omitempty"`
+	Teams         []*Team              `json:"teams,omitempty"`
+	TieBreakers   []string             `json:"tieBreakers,omitempty"`
+	Players       []DebugPlayer        `json:"players"`
+	Benched       []DebugPlayer        `json:"benched"`
+	Displays      int                  `json:"displays"`
+	Watchers      int                  `json:"watchers"`
+	Rounds        []*Round             `json:"rounds,omitempty"`
+	Question      *CurrentQuestion     `json:"question,omitempty"`
+	Phase         string               `json:"phase,omitempty"`
+	Deadline      *time.Time           `json:"deadline,omitempty"`
+	Closed        bool                 `json:"closed"`
+	Responses     map[string]*Response `json:"responses,omitempty"`
+	Wagers        map[string]int       `json:"wagers,omitempty"`
+	Buzzer        *BuzzerState         `json:"buzzer,omitempty"`
+	Asked         int                  `json:"asked"`
+	Scoreboard    Scoreboard           `json:"scoreboard"`
+}
+
+// A player without their socket and browser session.
+type DebugPlayer struct {
+	Name  string `json:"name"`
+	Team  string `json:"team,omitempty"`
+	Score int    `json:"score"`
+}
+
+func debugPlayers(players GamePlayers) []DebugPlayer {
+	debug := make([]DebugPlayer, len(players))
+	for i, player := range players {
+		debug[i] = DebugPlayer{
+			Name:  player.Name,
+			Team:  player.Team,
+			Score: player.Score,
+		}
+	}
+	return debug
+}
+
+// The caller must hold the game's lock.
+func (g *Game) DebugState() DebugState {
+	state := DebugState{
+		Name:          g.Name,
+		Seq:           len(g.Events),
+		State:         g.State,
+		KeyCreated:    g.Key.TimeCreated,
+		KeyExpiration: g.Key.Expiration,
+		KeyExpired:    g.health().KeyExpired,
+		AnswerPolicy:  g.AnswerPolicy,
+		TimeLimit:     g.TimeLimit.Seconds(),
+		TeamPolicy:    g.TeamPolicy,
+		Teams:         g.Teams,
+		TieBreakers:   g.TieBreakers,
+		Players:       debugPlayers(g.Players),
+		Benched:       debugPlayers(g.Benched),
+		Displays:      len(g.Displays),
+		Watchers:      len(g.Watchers),
+		Rounds:        g.Rounds,
+		Asked:         len(g.Questions),
+		Scoreboard:    g.GetScoreboard(),
+	}
+	if q := g.CurrentQuestion; q.Number > 0 {
+		state.Question = &q
+		state.Phase = q.Phase
+		state.Closed = q.Closed
+		state.Responses = q.Responses
+		state.Wagers = q.Wagers
+		if !q.Deadline.IsZero() {
+			state.Deadline = &q.Deadline
+		}
+		if q.Buzzes != nil {
+			buzzer := g.buzzerState()
+			state.Buzzer = &buzzer
+		}
+	}
+	return state
+}
+
+// Dumps the game's state as JSON, without its keys. The response is
+// marshaled while the game's lock is held, since it shares the
+// game's maps.
+func (s *SocketServer) DebugStateHandler(w http.ResponseWriter, r *http.Request) {
+	game, ok := s.hostGame(w, r)
+	if !ok {
+		return
+	}
+	game.mu.Lock()
+	b, err := json.MarshalIndent(game.DebugState(), "", "    ")
+	game.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,100 @@
+package server
+
+import "sort"
+
+// The standings of the active players after a question was closed.
+// See [Game.History].
+type Standing struct {
+	Question int            `json:"question"`
+	Scores   map[string]int `json:"scores"`
+	Ranks    map[string]int `json:"ranks"`
+}
+
+// Every player's score and rank after each question, suitable for
+// charting. A player that wasn't in the game at the time has a `null`
+// score and rank. The `Leaders` are whoever was ranked first after
+// each question, since there can be a tie.
+type Timeline struct {
+	Questions []int             `json:"questions"`
+	Players   []*PlayerTimeline `json:"players"`
+	Leaders   [][]string        `json:"leaders"`
+}
+
+type PlayerTimeline struct {
+	Name   string `json:"name"`
+	Scores []*int `json:"scores"`
+	Ranks  []*int `json:"ranks"`
+}
+
+// Ranks a sorted scoreboard. Players that are tied (after the
+// tie-breakers) share a rank, and the next rank is skipped (1, 1, 3).
+// The change is how many places a player has moved since the last
+// question, where a positive change means they moved up.
+func (g *Game) rank(scoreboard Scoreboard) {
+	var last *Standing
+	if len(g.History) > 0 {
+		last = &g.History[len(g.History)-1]
+	}
+	for i, score := range scoreboard {
+		if i > 0 && g.compareScores(score, scoreboard[i-1]) == 0 {
+			score.Rank = scoreboard[i-1].Rank
+		} else {
+			score.Rank = i + 1
+		}
+		if last != nil {
+			if previous, ok := last.Ranks[score.Name]; ok {
+				score.Change = previous - score.Rank
+			}
+		}
+	}
+}
+
+// Records the standings after the current question is closed.
+func (g *Game) recordStanding(scoreboard Scoreboard) {
+	standing := Standing{
+		Question: g.CurrentQuestion.Number,
+		Scores:   make(map[string]int, len(scoreboard)),
+		Ranks:    make(map[string]int, len(scoreboard)),
+	}
+	for _, score := range scoreboard {
+		standing.Scores[score.Name] = score.Score
+		standing.Ranks[score.Name] = score.Rank
+	}
+	g.History = append(g.History, standing)
+}
+
+func (g *Game) GetTimeline() *Timeline {
+	timeline := &Timeline{
+		Questions: make([]int, len(g.History)),
+		Players:   make([]*PlayerTimeline, 0),
+		Leaders:   make([][]string, len(g.History)),
+	}
+	players := make(map[string]*PlayerTimeline)
+	for i, standing := range g.History {
+		timeline.Questions[i] = standing.Question
+		timeline.Leaders[i] = make([]string, 0)
+		for name, rank := range standing.Ranks {
+			player, ok := players[name]
+			if !ok {
+				player = &PlayerTimeline{
+					Name:   name,
+					Scores: make([]*int, len(g.History)),
+					Ranks:  make([]*int, len(g.History)),
+				}
+				players[name] = player
+				timeline.Players = append(timeline.Players, player)
+			}
+			score, rank := standing.Scores[name], rank
+			player.Scores[i] = &score
+			player.Ranks[i] = &rank
+			if rank == 1 {
+				timeline.Leaders[i] = append(timeline.Leaders[i], name)
+			}
+		}
+		sort.Strings(timeline.Leaders[i])
+	}
+	sort.Slice(timeline.Players, func(i, j int) bool {
+		return timeline.Players[i].Name < timeline.Players[j].Name
+	})
+	return timeline
+}
@@ -0,0 +1,530 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/btoll/trivial/src/middleware"
+)
+
+// Every change to a game's state is an event that is appended to the
+// game's journal, and the state is what you get by applying the events
+// in order. See [Game.Apply].
+//
+// The journal doesn't keep what only matters while the game is live:
+// the sockets, the timers and who has buzzed in. A replayed game has
+// no one connected, and a replayed buzzer question has no buzzes.
+const (
+	EventGameCreated    = "GameCreated"
+	EventPlayerJoined   = "PlayerJoined"
+	EventPlayerBenched  = "PlayerBenched"
+	EventPlayerRejoined = "PlayerRejoined"
+	EventStateChanged   = "StateChanged"
+	EventRoundBegun     = "RoundBegun"
+	EventQuestionAsked  = "QuestionAsked"
+	EventQuestionShown  = "QuestionShown"
+	EventWagerPlaced    = "WagerPlaced"
+	EventGuessSubmitted = "GuessSubmitted"
+	EventQuestionClosed = "QuestionClosed"
+	EventScoreAdjusted  = "ScoreAdjusted"
+	EventScoresReset    = "ScoresReset"
+	EventHostActed      = "HostActed"
+	EventScoresUndone   = "ScoresUndone"
+	EventScoresRestored = "ScoresRestored"
+)
+
+// The events are numbered from one in the order they happened.
+type Event struct {
+	Seq  int             `json:"seq"`
+	Time time.Time       `json:"time"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// The game's settings, which are always its first event. The game's
+// keys are kept so that a recovered game still has them, but they're
+// left out whenever the journal is served.
+type GameCreated struct {
+	Name          string             `json:"name"`
+	Key           *middleware.APIKey `json:"key,omitempty"`
+	DisplayKey    string             `json:"displayKey,omitempty"`
+	HostKey       string             `json:"hostKey,omitempty"`
+	AnswerPolicy  string             `json:"answerPolicy"`
+	TimeLimit     time.Duration      `json:"timeLimit"`
+	TeamPolicy    string             `json:"teamPolicy,omitempty"`
+	Teams         []string           `json:"teams,omitempty"`
+	TieBreakers   []string           `json:"tieBreakers,omitempty"`
+	SuddenDeath   *AskedQuestion     `json:"suddenDeath,omitempty"`
+	BuzzerDelay   time.Duration      `json:"buzzerDelay"`
+	BuzzerLockout time.Duration      `json:"buzzerLockout"`
+	BuzzerWindow  time.Duration      `json:"buzzerWindow"`
+	IdleTimeout   time.Duration      `json:"idleTimeout"`
+}
+
+type PlayerJoined struct {
+	Name     string `json:"name"`
+	UUID     string `json:"uuid,omitempty"`
+	Secret   string `json:"secret,omitempty"`
+	Location string `json:"location,omitempty"`
+	Team     string `json:"team,omitempty"`
+}
+
+// A player is benched when they leave, when they go idle (see
+// [SocketServer.watchIdle]), when they're kicked and when the game is
+// recovered, since no one is connected anymore.
+type PlayerBenched struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+type PlayerRejoined struct {
+	Name string `json:"name"`
+}
+
+type StateChanged struct {
+	State string `json:"state"`
+}
+
+type RoundBegun struct {
+	Title      string  `json:"title"`
+	Category   string  `json:"category,omitempty"`
+	Multiplier float64 `json:"multiplier"`
+	Final      bool    `json:"final,omitempty"`
+	Buzzer     bool    `json:"buzzer,omitempty"`
+}
+
+// A question as it was asked, along with what the players are never
+// sent: its media path and the order of an ordering question. The
+// answer of a multiple choice question is a bitmap, which is kept
+// apart since it would otherwise come back from JSON as a float64.
+type AskedQuestion struct {
+	CurrentQuestion
+	Media  string `json:"media,omitempty"`
+	Order  []int  `json:"order,omitempty"`
+	Bitmap uint16 `json:"bitmap,omitempty"`
+}
+
+type QuestionShown struct {
+	Number int `json:"number"`
+}
+
+type WagerPlaced struct {
+	Name   string `json:"name"`
+	Amount int    `json:"amount"`
+}
+
+type GuessSubmitted struct {
+	Name  string `json:"name"`
+	Guess any    `json:"guess"`
+}
+
+type QuestionClosed struct {
+	Number int `json:"number"`
+}
+
+// The host adjusts the score of either a player or a team.
+type ScoreAdjusted struct {
+	Player string `json:"player,omitempty"`
+	Team   string `json:"team,omitempty"`
+	Points int    `json:"points"`
+}
+
+type ScoresReset struct{}
+
+var eventData = map[string]func() any{
+	EventGameCreated:    func() any { return &GameCreated{} },
+	EventPlayerJoined:   func() any { return &PlayerJoined{} },
+	EventPlayerBenched:  func() any { return &PlayerBenched{} },
+	EventPlayerRejoined: func() any { return &PlayerRejoined{} },
+	EventStateChanged:   func() any { return &StateChanged{} },
+	EventRoundBegun:     func() any { return &RoundBegun{} },
+	EventQuestionAsked:  func() any { return &AskedQuestion{} },
+	EventQuestionShown:  func() any { return &QuestionShown{} },
+	EventWagerPlaced:    func() any { return &WagerPlaced{} },
+	EventGuessSubmitted: func() any { return &GuessSubmitted{} },
+	EventQuestionClosed: func() any { return &QuestionClosed{} },
+	EventScoreAdjusted:  func() any { return &ScoreAdjusted{} },
+	EventScoresReset:    func() any { return &ScoresReset{} },
+	EventHostActed:      func() any { return &AuditEntry{} },
+	EventScoresUndone:   func() any { return &ScoresUndone{} },
+	EventScoresRestored: func() any { return &ScoresRestored{} },
+}
+
+func (e Event) decode() (any, error) {
+	f, ok := eventData[e.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown event `%s`", e.Type)
+	}
+	data := f()
+	if len(e.Data) > 0 {
+		if err := json.Unmarshal(e.Data, data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// The event as the host sees it, without the game's keys or the
+// players' secrets.
+func (e Event) public() Event {
+	switch e.Type {
+	case EventGameCreated:
+		var created GameCreated
+		if err := json.Unmarshal(e.Data, &created); err != nil {
+			return e
+		}
+		created.Key = nil
+		created.DisplayKey = ""
+		created.HostKey = ""
+		e.Data, _ = json.Marshal(created)
+	case EventPlayerJoined:
+		var joined PlayerJoined
+		if err := json.Unmarshal(e.Data, &joined); err != nil {
+			return e
+		}
+		joined.Secret = ""
+		e.Data, _ = json.Marshal(joined)
+	}
+	return e
+}
+
+func askedQuestion(q CurrentQuestion) AskedQuestion {
+	asked := AskedQuestion{
+		CurrentQuestion: q,
+		Media:           q.Media,
+		Order:           q.Order,
+	}
+	if bitmap, ok := q.Answer.(uint16); ok {
+		asked.Answer = nil
+		asked.Bitmap = bitmap
+	}
+	return asked
+}
+
+func (a AskedQuestion) question() CurrentQuestion {
+	q := a.CurrentQuestion
+	q.Media = a.Media
+	q.Order = a.Order
+	if a.Bitmap != 0 {
+		q.Answer = a.Bitmap
+	}
+	return q
+}
+
+// The game's settings as its first event.
+func (g *Game) settings() GameCreated {
+	key := g.Key
+	created := GameCreated{
+		Name:          g.Name,
+		Key:           &key,
+		DisplayKey:    g.DisplayKey,
+		HostKey:       g.HostKey,
+		AnswerPolicy:  g.AnswerPolicy,
+		TimeLimit:     g.TimeLimit,
+		TeamPolicy:    g.TeamPolicy,
+		TieBreakers:   g.TieBreakers,
+		BuzzerDelay:   g.BuzzerDelay,
+		BuzzerLockout: g.BuzzerLockout,
+		BuzzerWindow:  g.BuzzerWindow,
+		IdleTimeout:   g.IdleTimeout,
+	}
+	for _, team := range g.Teams {
+		created.Teams = append(created.Teams, team.Name)
+	}
+	if g.SuddenDeath != nil {
+		question := askedQuestion(*g.SuddenDeath)
+		created.SuddenDeath = &question
+	}
+	return created
+}
+
+// Records an event and applies it to the game. The event is only
+// added to the journal once it has been applied, and a journal that
+// can't be written to doesn't stop the game.
+// The caller must hold the game's lock.
+func (g *Game) emit(kind string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	// The time is stripped of its monotonic clock reading, which
+	// isn't journaled, so that the time between events is the same
+	// when the game is replayed.
+	e := Event{
+		Seq:  len(g.Events) + 1,
+		Time: time.Now().Round(0),
+		Type: kind,
+		Data: b,
+	}
+	if err := g.Apply(e); err != nil {
+		return err
+	}
+	g.Events = append(g.Events, e)
+	if g.Journal != nil {
+		if err := g.Journal.Append(e); err != nil {
+			fmt.Println("journal error:", err)
+		}
+	}
+	return nil
+}
+
+// Applies an event to the game's state. An event was checked before
+// it was emitted, so it's applied without being checked again, and it
+// uses the time of the event rather than the clock, so that it has
+// the same effect whether the game is live or being replayed.
+// The caller must hold the game's lock.
+func (g *Game) Apply(e Event) error {
+	data, err := e.decode()
+	if err != nil {
+		return err
+	}
+	switch d := data.(type) {
+	case *GameCreated:
+		g.create(d)
+	case *PlayerJoined:
+		g.join(d)
+	case *PlayerBenched:
+		if err := g.move(&g.Players, &g.Benched, d.Name); err != nil {
+			return err
+		}
+		g.Benched[len(g.Benched)-1].BenchReason = d.Reason
+		g.replaceCaptain(d.Name)
+	case *PlayerRejoined:
+		if err := g.move(&g.Benched, &g.Players, d.Name); err != nil {
+			return err
+		}
+		g.Players[len(g.Players)-1].BenchReason = ""
+		g.rejoinTeam(d.Name)
+	case *StateChanged:
+		g.changeState(d.State, e.Time)
+	case *RoundBegun:
+		g.beginRound(d)
+	case *AskedQuestion:
+		g.ask(d.question())
+	case *QuestionShown:
+		g.show(e.Time)
+	case *WagerPlaced:
+		g.CurrentQuestion.Wagers[d.Name] = d.Amount
+	case *GuessSubmitted:
+		g.respond(d.Name, d.Guess, e.Time)
+	case *QuestionClosed:
+		g.close()
+	case *ScoreAdjusted:
+		return g.adjustScore(d)
+	case *ScoresReset:
+		g.resetScores()
+	case *AuditEntry:
+		d.Seq = len(g.Audit) + 1
+		d.Time = e.Time
+		g.Audit = append(g.Audit, *d)
+	case *ScoresUndone:
+		g.undoScores(d)
+	case *ScoresRestored:
+		g.restoreScores(d)
+	}
+	return nil
+}
+
+func (g *Game) create(d *GameCreated) {
+	g.Name = d.Name
+	if d.Key != nil {
+		g.Key = *d.Key
+	}
+	g.DisplayKey = d.DisplayKey
+	g.HostKey = d.HostKey
+	g.AnswerPolicy = d.AnswerPolicy
+	g.TimeLimit = d.TimeLimit
+	g.TeamPolicy = d.TeamPolicy
+	g.Teams = nil
+	for _, name := range d.Teams {
+		g.Teams = append(g.Teams, &Team{Name: name})
+	}
+	g.TieBreakers = d.TieBreakers
+	g.SuddenDeath = nil
+	if d.SuddenDeath != nil {
+		question := d.SuddenDeath.question()
+		g.SuddenDeath = &question
+	}
+	g.BuzzerDelay = d.BuzzerDelay
+	g.BuzzerLockout = d.BuzzerLockout
+	g.BuzzerWindow = d.BuzzerWindow
+	g.IdleTimeout = d.IdleTimeout
+}
+
+func (g *Game) move(from, to *GamePlayers, name string) error {
+	n, player := has(*from, name)
+	if n == -1 {
+		return fmt.Errorf("player `%s` not found", name)
+	}
+	*from = remove(*from, n)
+	*to = append(*to, player)
+	return nil
+}
+
+// Rebuilds a game by applying its events in order, the first of which
+// must be the game's creation.
+func ReplayGame(events []Event) (*Game, error) {
+	if len(events) == 0 || events[0].Type != EventGameCreated {
+		return nil, errors.New("the journal doesn't begin with the game's creation")
+	}
+	g := &Game{
+		State:   StateLobby,
+		Players: make(GamePlayers, 0),
+	}
+	for _, e := range events {
+		if err := g.Apply(e); err != nil {
+			return nil, fmt.Errorf("event %d: %v", e.Seq, err)
+		}
+	}
+	g.Events = append([]Event(nil), events...)
+	return g, nil
+}
+
+// A game's journal on disk, with one event per line, so that the game
+// can be recovered after a crash. See [SocketServer.Recover].
+type Journal struct {
+	Path string
+	file *os.File
+}
+
+// Opens a journal, creating it if it doesn't exist, and returns the
+// events that it already has.
+func OpenJournal(path string) (*Journal, []Event, error) {
+	events, err := ReadJournal(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Journal{Path: path, file: f}, events, nil
+}
+
+func ReadJournal(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	events := make([]Event, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
+
+// Each event is synced to disk before the next one is written, so
+// that a crash loses at most the event that was being written.
+func (j *Journal) Append(e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
+
+// Picks a game back up once it has been replayed from its journal.
+// No one is connected anymore, so every player is benched until they
+// log back in, and an open question gets its timers back.
+// The caller must hold the game's lock.
+func (s *SocketServer) Recover(game *Game) error {
+	for len(game.Players) > 0 {
+		if err := game.Bench(game.Players[0], "restart"); err != nil {
+			return err
+		}
+	}
+	q := &game.CurrentQuestion
+	if !game.IsOpen() || q.Phase != PhaseOpen {
+		return nil
+	}
+	if !q.Deadline.IsZero() {
+		end := time.Now()
+		if game.State == StatePaused {
+			end = game.paused
+		}
+		remaining := q.Deadline.Sub(end)
+		if remaining < 0 {
+			remaining = 0
+		}
+		s.startTimer(game, remaining)
+	}
+	if q.Buzzer {
+		s.armBuzzer(game)
+	}
+	if game.State == StatePaused {
+		game.pauseTimers()
+	}
+	return nil
+}
+
+// Lists the game's events, without its keys or the players' secrets.
+func (s *SocketServer) JournalHandler(w http.ResponseWriter, r *http.Request) {
+	game, ok := s.hostGame(w, r)
+	if !ok {
+		return
+	}
+	game.mu.Lock()
+	events := make([]Event, len(game.Events))
+	for i, e := range game.Events {
+		events[i] = e.public()
+	}
+	game.mu.Unlock()
+	writeJSON(w, http.StatusOK, events)
+}
+
+// Replays the game's events up to and including `seq` (or all of them)
+// and dumps the state that it had then, like [SocketServer.DebugStateHandler].
+func (s *SocketServer) ReplayHandler(w http.ResponseWriter, r *http.Request) {
+	game, ok := s.hostGame(w, r)
+	if !ok {
+		return
+	}
+	game.mu.Lock()
+	events := append([]Event(nil), game.Events...)
+	game.mu.Unlock()
+	seq := len(events)
+	if v := r.URL.Query().Get("seq"); v != "" {
+		var err error
+		seq, err = strconv.Atoi(v)
+		if err != nil || seq < 1 || seq > len(events) {
+			http.Error(w, fmt.Sprintf("seq must be between 1 and %d", len(events)), http.StatusBadRequest)
+			return
+		}
+	}
+	replayed, err := ReplayGame(events[:seq])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	b, err := json.MarshalIndent(replayed.DebugState(), "", "    ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,297 @@
+package server
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A game that's recorded in a journal in a temporary directory, as
+// it is with `-journal`. No one is connected, so nothing is sent.
+func newTestGame(t *testing.T, setup func(*Game)) (*SocketServer, *Game) {
+	t.Helper()
+	s := NewSocketServer(URL{})
+	g := NewGame("test", 3600)
+	if setup != nil {
+		setup(g)
+	}
+	j, _, err := OpenJournal(filepath.Join(t.TempDir(), "game.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		j.Close()
+	})
+	g.Journal = j
+	s.RegisterGame(g)
+	return s, g
+}
+
+func withTeams(policy string, teams ...string) func(*Game) {
+	return func(g *Game) {
+		g.TeamPolicy = policy
+		for _, name := range teams {
+			g.Teams = append(g.Teams, &Team{Name: name})
+		}
+	}
+}
+
+func mustDo(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func join(t *testing.T, g *Game, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		_, err := g.Join(name, name, "https://127.0.0.1:3000", "")
+		mustDo(t, err)
+	}
+}
+
+func player(t *testing.T, g *Game, name string) *Player {
+	t.Helper()
+	p, _ := g.HasPlayer(name)
+	if p == nil {
+		t.Fatalf("player `%s` not found", name)
+	}
+	return p
+}
+
+// Asks a question in the format of a deck's line.
+func ask(t *testing.T, s *SocketServer, g *Game, line string) {
+	t.Helper()
+	question, err := parseQuestion(line)
+	mustDo(t, err)
+	mustDo(t, s.Ask(g, question))
+}
+
+func guess(t *testing.T, s *SocketServer, g *Game, name string, guess any) {
+	t.Helper()
+	_, err := s.Guess(g, player(t, g, name), guess)
+	mustDo(t, err)
+}
+
+var host = Actor{Name: "host", Address: "127.0.0.1"}
+
+// Everything about the game that its events decide.
+type foldedGame struct {
+	State          DebugState
+	TeamScoreboard TeamScoreboard
+	History        any
+	Questions      any
+	Audit          []AuditEntry
+}
+
+func fold(t *testing.T, g *Game) string {
+	t.Helper()
+	folded := foldedGame{
+		State:     g.DebugState(),
+		History:   g.History,
+		Questions: g.Questions,
+		Audit:     g.Audit,
+	}
+	if g.TeamPolicy != "" {
+		folded.TeamScoreboard = g.GetTeamScoreboard()
+	}
+	b, err := json.MarshalIndent(folded, "", "  ")
+	mustDo(t, err)
+	return string(b)
+}
+
+// Folds the game's journal as it was written to disk, and checks that
+// it comes out the same as the game that wrote it.
+func checkReplay(t *testing.T, g *Game) *Game {
+	t.Helper()
+	events, err := ReadJournal(g.Journal.Path)
+	mustDo(t, err)
+	if len(events) != len(g.Events) {
+		t.Fatalf("the journal has %d events, but the game has %d", len(events), len(g.Events))
+	}
+	replayed, err := ReplayGame(events)
+	mustDo(t, err)
+	if live, folded := fold(t, g), fold(t, replayed); live != folded {
+		t.Errorf("the folded journal isn't the game\nlive:\n%s\nfolded:\n%s", live, folded)
+	}
+	return replayed
+}
+
+func TestReplayGame(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(*Game)
+		play  func(t *testing.T, s *SocketServer, g *Game)
+	}{
+		{
+			name: "players join, leave and rejoin",
+			play: func(t *testing.T, s *SocketServer, g *Game) {
+				join(t, g, "alice", "bob", "carl")
+				mustDo(t, g.Bench(player(t, g, "bob"), "left"))
+				mustDo(t, g.Bench(player(t, g, "carl"), "kicked"))
+				mustDo(t, g.Unbench(player(t, g, "bob")))
+			},
+		},
+		{
+			name: "questions of every kind",
+			play: func(t *testing.T, s *SocketServer, g *Game) {
+				join(t, g, "alice", "bob")
+				mustDo(t, s.SetState(g, StateRunning))
+				ask(t, s, g, "Which are primes?|10|1,3|2|4|5")
+				guess(t, s, g, "alice", float64(5))
+				guess(t, s, g, "bob", float64(1))
+				ask(t, s, g, "In what year was Woodstock?|20|number:1969")
+				guess(t, s, g, "alice", float64(1968))
+				guess(t, s, g, "bob", float64(1969))
+				ask(t, s, g, "What's the capital of France?|30|Paris")
+				guess(t, s, g, "alice", " paris ")
+				ask(t, s, g, "Order them by size|40|order:partial|ant|cat|horse")
+				guess(t, s, g, "bob", []any{float64(0), float64(1), float64(2)})
+				mustDo(t, s.Reveal(g))
+			},
+		},
+		{
+			name: "an open question",
+			play: func(t *testing.T, s *SocketServer, g *Game) {
+				join(t, g, "alice", "bob")
+				mustDo(t, s.SetState(g, StateRunning))
+				ask(t, s, g, "What's the capital of France?|30|Paris")
+				guess(t, s, g, "bob", "Lyon")
+			},
+		},
+		{
+			name: "the host adjusts and resets the scores",
+			play: func(t *testing.T, s *SocketServer, g *Game) {
+				join(t, g, "alice", "bob")
+				mustDo(t, s.SetState(g, StateRunning))
+				_, err := s.AddPlayerPoints(g, host, "alice", 50)
+				mustDo(t, err)
+				_, err = s.AddPlayerPoints(g, host, "bob", -20)
+				mustDo(t, err)
+				mustDo(t, s.ResetScores(g, host))
+				_, err = s.AddPlayerPoints(g, host, "bob", 5)
+				mustDo(t, err)
+			},
+		},
+		{
+			name:  "teams",
+			setup: withTeams(TeamCaptain, "Red", "Blue"),
+			play: func(t *testing.T, s *SocketServer, g *Game) {
+				join(t, g, "alice", "bob", "carl", "dave")
+				mustDo(t, s.SetState(g, StateRunning))
+				ask(t, s, g, "In what year was Woodstock?|20|number:1969")
+				guess(t, s, g, "alice", float64(1969))
+				guess(t, s, g, "bob", float64(1969))
+				guess(t, s, g, "carl", float64(1970))
+				guess(t, s, g, "dave", float64(1969))
+				// The captain leaves, and the next player takes over.
+				mustDo(t, g.Bench(player(t, g, "alice"), "left"))
+				_, err := s.AddTeamPoints(g, host, "Blue", 15)
+				mustDo(t, err)
+			},
+		},
+		{
+			name: "wagers",
+			play: func(t *testing.T, s *SocketServer, g *Game) {
+				join(t, g, "alice", "bob")
+				mustDo(t, s.SetState(g, StateRunning))
+				_, err := s.AddPlayerPoints(g, host, "alice", 100)
+				mustDo(t, err)
+				ask(t, s, g, "In what year was Woodstock?|wager:History|number:1969")
+				mustDo(t, g.PlaceWager(player(t, g, "alice"), 60))
+				mustDo(t, g.PlaceWager(player(t, g, "bob"), 0))
+				mustDo(t, s.Show(g))
+				guess(t, s, g, "alice", float64(1969))
+				guess(t, s, g, "bob", float64(1969))
+			},
+		},
+		{
+			name: "paused and finished",
+			play: func(t *testing.T, s *SocketServer, g *Game) {
+				join(t, g, "alice")
+				mustDo(t, s.SetState(g, StateRunning))
+				ask(t, s, g, "What's the capital of France?|30|Paris")
+				mustDo(t, s.SetState(g, StatePaused))
+				mustDo(t, s.SetState(g, StateRunning))
+				guess(t, s, g, "alice", "Paris")
+				mustDo(t, s.SetState(g, StateFinished))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, g := newTestGame(t, tt.setup)
+			tt.play(t, s, g)
+			checkReplay(t, g)
+		})
+	}
+}
+
+// A game that's recovered from its journal picks up where it left off,
+// with everyone benched until they log back in.
+func TestRecover(t *testing.T) {
+	s, g := newTestGame(t, nil)
+	join(t, g, "alice", "bob")
+	mustDo(t, s.SetState(g, StateRunning))
+	ask(t, s, g, "In what year was Woodstock?|20|number:1969")
+	guess(t, s, g, "alice", float64(1969))
+	guess(t, s, g, "bob", float64(1969))
+	ask(t, s, g, "What's the capital of France?|30|Paris")
+	guess(t, s, g, "bob", "paris")
+	replayed := checkReplay(t, g)
+
+	// The replayed game is recovered into the same journal.
+	j, events, err := OpenJournal(g.Journal.Path)
+	mustDo(t, err)
+	defer j.Close()
+	if len(events) != len(g.Events) {
+		t.Fatalf("the journal has %d events, but the game has %d", len(events), len(g.Events))
+	}
+	replayed.Journal = j
+	mustDo(t, NewSocketServer(URL{}).Recover(replayed))
+	if len(replayed.Players) != 0 || len(replayed.Benched) != 2 {
+		t.Fatalf("got %d players and %d benched, want everyone benched", len(replayed.Players), len(replayed.Benched))
+	}
+	for _, p := range replayed.Benched {
+		if p.BenchReason != "restart" {
+			t.Errorf("%s was benched for %q, want %q", p.Name, p.BenchReason, "restart")
+		}
+	}
+	got := make(map[string]int)
+	for _, p := range replayed.Benched {
+		got[p.Name] = p.Score
+	}
+	if want := map[string]int{"alice": 20, "bob": 20}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got scores %v, want %v", got, want)
+	}
+	if q := replayed.CurrentQuestion; !replayed.IsOpen() || q.Number != 2 || q.Responses["bob"] == nil {
+		t.Errorf("question %d isn't open with bob's guess", q.Number)
+	}
+	// And the benching is in the journal too.
+	checkReplay(t, replayed)
+}
+
+// The journal that the host reads doesn't give away the keys or the
+// players' secrets.
+func TestPublicEvents(t *testing.T) {
+	_, g := newTestGame(t, nil)
+	join(t, g, "alice")
+	secret := player(t, g, "alice").Secret
+	for _, e := range g.Events {
+		b, err := json.Marshal(e.public())
+		mustDo(t, err)
+		for _, key := range []string{g.Key.Key, g.DisplayKey, g.HostKey, secret} {
+			if strings.Contains(string(b), key) {
+				t.Errorf("the public %s event has a key or secret: %s", e.Type, b)
+			}
+		}
+	}
+	// Though the journal on disk has them, so the game can be recovered.
+	replayed := checkReplay(t, g)
+	if replayed.HostKey != g.HostKey || player(t, replayed, "alice").Secret != secret {
+		t.Error("the replayed game doesn't have the host key and the players' secrets")
+	}
+}
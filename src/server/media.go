@@ -0,0 +1,93 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The kinds of media that can be embedded in a question.
+const (
+	MediaAudio = "audio"
+	MediaImage = "image"
+)
+
+// A question can reference a media file in the deck directory
+// by prefixing the question with its path in square brackets:
+//
+//	[covers/abbey_road.jpg] Name this album.|50|1|Abbey Road|Let It Be
+//
+// Returns the path of the media file (if any) and the question.
+func parseMedia(s string) (string, string) {
+	if !strings.HasPrefix(s, "[") {
+		return "", s
+	}
+	media, question, found := strings.Cut(s[1:], "]")
+	if !found {
+		return "", s
+	}
+	return strings.TrimSpace(media), strings.TrimSpace(question)
+}
+
+// Resolves the path of a media file relative to the deck directory
+// and determines its type from the file extension. The path can
+// never escape the deck directory.
+func (s *SocketServer) resolveMedia(name string) (string, string, error) {
+	if s.Deck == "" {
+		return "", "", errors.New("no deck directory has been configured for media")
+	}
+	p := filepath.Join(s.Deck, filepath.Clean("/"+name))
+	info, err := os.Stat(p)
+	if err != nil {
+		return "", "", err
+	}
+	if info.IsDir() {
+		return "", "", fmt.Errorf("media `%s` is a directory", name)
+	}
+	kind, _, _ := strings.Cut(mime.TypeByExtension(filepath.Ext(p)), "/")
+	if kind != MediaAudio && kind != MediaImage {
+		return "", "", fmt.Errorf("media `%s` is neither an image nor audio", name)
+	}
+	return p, kind, nil
+}
+
+// A random id that can't be guessed. Every published question gets
+// a new one for its media, so the players can't guess the URL ahead
+// of time.
+func newRandomID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Serves the media of the current question at `/media/{id}`.
+// This bypasses the [middleware.Authenticator], since the browser
+// doesn't send the API key header when loading an image or audio,
+// so the file is only served while its question is open.
+func (s *SocketServer) MediaHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/media/")
+	if id == "" {
+		http.NotFound(w, r)
+		return
+	}
+	for _, game := range s.Games {
+		game.mu.Lock()
+		active := game.IsOpen() && game.CurrentQuestion.MediaID == id
+		media := game.CurrentQuestion.Media
+		game.mu.Unlock()
+		if active {
+			w.Header().Set("Cache-Control", "no-store")
+			http.ServeFile(w, r, media)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/btoll/trivial/src/metrics"
+	"golang.org/x/net/websocket"
+)
+
+var (
+	messagesReceived = metrics.NewCounter(
+		"trivial_websocket_messages_received_total",
+		"Websocket messages received from the players, displays and watchers, by type.",
+		"type")
+	messagesSent = metrics.NewCounter(
+		"trivial_websocket_messages_sent_total",
+		"Websocket messages sent to the players, displays and watchers, by type.",
+		"type")
+	writeErrors = metrics.NewCounter(
+		"trivial_websocket_write_errors_total",
+		"Websocket messages that couldn't be sent, by type.",
+		"type")
+	responseTime = metrics.NewHistogram(
+		"trivial_question_response_seconds",
+		"How long after a question was asked the players answered it.",
+		[]float64{1, 2, 5, 10, 15, 20, 30, 45, 60, 90, 120},
+		"game")
+)
+
+// The types of the messages that the server understands. Anything
+// else is counted as `unknown`, so a client can't make up labels.
+var clientMessageTypes = map[string]bool{
+	"buzz":    true,
+	"display": true,
+	"guess":   true,
+	"login":   true,
+	"wager":   true,
+	"watch":   true,
+}
+
+func clientMessageType(msgType string) string {
+	if clientMessageTypes[msgType] {
+		return msgType
+	}
+	return "unknown"
+}
+
+// Registers the gauges that are read from the games when the metrics
+// are scraped.
+func (s *SocketServer) registerMetrics() {
+	gauge := func(name, help string, count func(*Game) int) {
+		metrics.NewGaugeFunc(name, help, func() []metrics.Sample {
+			samples := make([]metrics.Sample, 0, len(s.Games))
+			for _, game := range s.Games {
+				game.mu.Lock()
+				samples = append(samples, metrics.Sample{
+					Labels: []string{game.Name},
+					Value:  float64(count(game)),
+				})
+				game.mu.Unlock()
+			}
+			return samples
+		}, "game")
+	}
+	gauge("trivial_players", "Players that are connected, by game.", func(game *Game) int {
+		return len(game.Players)
+	})
+	gauge("trivial_benched_players", "Players that have left or been kicked, by game.", func(game *Game) int {
+		return len(game.Benched)
+	})
+	gauge("trivial_displays", "Displays that are connected, by game.", func(game *Game) int {
+		return len(game.Displays)
+	})
+	gauge("trivial_watchers", "Hosts that are watching, by game.", func(game *Game) int {
+		return len(game.Watchers)
+	})
+}
+
+// Writes a message that's already been marshaled and counts it.
+func write(socket *websocket.Conn, msgType string, b []byte) error {
+	if _, err := socket.Write(b); err != nil {
+		writeErrors.Inc(msgType)
+		return fmt.Errorf("websocket write error: %v", err)
+	}
+	messagesSent.Inc(msgType)
+	return nil
+}
+
+// The caller must hold the game's lock.
+func observeResponse(game *Game, response *Response) {
+	responseTime.Observe(response.Received.Sub(game.CurrentQuestion.Asked).Seconds(), game.Name)
+}
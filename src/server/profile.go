@@ -0,0 +1,112 @@
+package server
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/btoll/trivial/src/profile"
+	"golang.org/x/net/websocket"
+)
+
+// What a player is sent once they've logged in to their profile.
+// The `Token` is kept by their browser to log in again.
+type ProfileMessage struct {
+	Name     string  `json:"name"`
+	Token    string  `json:"token"`
+	Games    int     `json:"games"`
+	Wins     int     `json:"wins"`
+	Accuracy float64 `json:"accuracy"`
+}
+
+// When profiles are kept, the player logs in to their profile with
+// the token that their browser was given when it was claimed.
+// Returns false when the name belongs to someone else.
+// The caller must hold the game's lock.
+func (s *SocketServer) claimProfile(socket *websocket.Conn, name string, data any) bool {
+	if s.Profiles == nil {
+		return true
+	}
+	var token string
+	if data, ok := data.(map[string]any); ok {
+		token, _ = data["profile"].(string)
+	}
+	p, token, err := s.Profiles.Claim(name, token)
+	if err != nil {
+		err = s.Message(socket, ServerMessage{
+			Type: "error",
+			Data: err.Error(),
+		})
+		if err != nil {
+			log.Fatalln(err)
+		}
+		return false
+	}
+	err = s.Message(socket, ServerMessage{
+		Type: "profile",
+		Data: ProfileMessage{
+			Name:     p.Name,
+			Token:    token,
+			Games:    p.Games,
+			Wins:     p.Wins,
+			Accuracy: profile.Accuracy{Answered: p.Answered, Correct: p.Correct}.Percent(),
+		},
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return true
+}
+
+// How each player did in the game, for their profile. Everyone
+// ranked first has won, and the players that left the game are
+// counted as having played it.
+func (g *Game) GetResults() []profile.Result {
+	results := make([]profile.Result, 0, len(g.Players)+len(g.Benched))
+	index := make(map[string]int)
+	for _, score := range g.GetScoreboard() {
+		index[score.Name] = len(results)
+		results = append(results, profile.Result{
+			Name:       score.Name,
+			Points:     score.Score,
+			Won:        score.Rank == 1,
+			Categories: make(map[string]*profile.Accuracy),
+		})
+	}
+	for _, player := range g.Benched {
+		index[player.Name] = len(results)
+		results = append(results, profile.Result{
+			Name:       player.Name,
+			Points:     player.Score,
+			Categories: make(map[string]*profile.Accuracy),
+		})
+	}
+	for _, q := range g.Questions {
+		for name, response := range q.Responses {
+			i, ok := index[name]
+			if !ok {
+				continue
+			}
+			accuracy, ok := results[i].Categories[q.Category]
+			if !ok {
+				accuracy = &profile.Accuracy{}
+				results[i].Categories[q.Category] = accuracy
+			}
+			accuracy.Answered++
+			if response.Correct {
+				accuracy.Correct++
+			}
+		}
+	}
+	return results
+}
+
+// Adds the finished game to the players' profiles.
+// The caller must hold the game's lock.
+func (s *SocketServer) recordProfiles(game *Game) {
+	if s.Profiles == nil {
+		return
+	}
+	if err := s.Profiles.Record(game.GetResults()); err != nil {
+		fmt.Println("profiles:", err)
+	}
+}
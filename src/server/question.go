@@ -0,0 +1,288 @@
+package server
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Question kinds. The empty kind is the original multiple choice
+// question whose answer is a bitmap, and a free-form question, which
+// has no choices, is answered with text.
+const (
+	KindOrder  = "order"
+	KindNumber = "number"
+	KindText   = "text"
+)
+
+// An ordering question is scored either all-or-nothing (the
+// default) or with partial credit for every choice that the
+// player put in its correct position.
+const (
+	ScoreExact   = "exact"
+	ScorePartial = "partial"
+)
+
+// Parses a single line of a deck into a question. The original
+// format is:
+//
+//	Question|weight|answers|choice|choice|...
+//
+// An ordering question uses the keyword `order` (or `order:partial`
+// for per-position partial credit) in place of the answers and lists
+// the choices in their correct order. The choices are shuffled before
+// they're sent to the players, and the correct permutation is kept
+// on the server.
+//
+//	Put these albums in order.|50|order|Rubber Soul|Revolver|Abbey Road
+//
+// A wager question uses the keyword `wager` (optionally followed by
+// its category, otherwise it's the category of the round) in place
+// of the weight. The players see the category and place their wagers
+// before the question is shown.
+//
+//	Who sang "Video Killed the Radio Star"?|wager:One-Hit Wonders|2|Devo|The Buggles|A-ha
+//
+// A numeric question uses `number:` followed by the answer in place
+// of the answers and has no choices. The players type in a number.
+//
+//	In what year was Woodstock?|50|number:1969
+//
+// A free-form question has no choices, and its answer is the text that
+// the players have to type in (which isn't case-sensitive).
+//
+//	Who played bass in the Beatles?|50|Paul McCartney
+//
+// Any kind of question can embed an image or audio file.
+// See [parseMedia].
+func parseQuestion(s string) (CurrentQuestion, error) {
+	req, err := ParseQuestionRequest(s)
+	if err != nil {
+		return CurrentQuestion{}, err
+	}
+	return req.toQuestion()
+}
+
+// Parses a single line of a deck into the body of a request to
+// `POST /api/v1/questions`. See [parseQuestion] for the format.
+func ParseQuestionRequest(s string) (QuestionRequest, error) {
+	l := strings.Split(s, "|")
+	if len(l) < 3 {
+		return QuestionRequest{}, fmt.Errorf("malformed question `%s`", s)
+	}
+	var req QuestionRequest
+	req.Media, req.Question = parseMedia(l[0])
+	if kind, category, _ := strings.Cut(l[1], ":"); kind == "wager" {
+		req.Wager = true
+		req.Category = category
+	} else {
+		weight, err := strconv.Atoi(l[1])
+		if err != nil {
+			return QuestionRequest{}, err
+		}
+		req.Weight = weight
+	}
+	if len(l) > 3 {
+		req.Choices = l[3:]
+	}
+	switch kind, rest, _ := strings.Cut(l[2], ":"); kind {
+	case KindOrder:
+		req.Kind = KindOrder
+		req.Scoring = rest
+	case KindNumber:
+		n, err := strconv.ParseFloat(strings.TrimSpace(rest), 64)
+		if err != nil {
+			return QuestionRequest{}, err
+		}
+		req.Kind = KindNumber
+		req.Number = &n
+	default:
+		if len(req.Choices) == 0 {
+			req.Kind = KindText
+			req.Text = strings.TrimSpace(l[2])
+			break
+		}
+		for _, answer := range strings.Split(l[2], ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(answer))
+			if err != nil {
+				return QuestionRequest{}, fmt.Errorf("malformed answer `%s`", answer)
+			}
+			req.Answers = append(req.Answers, n)
+		}
+	}
+	return req, nil
+}
+
+// Returns the choices in a random order along with the correct
+// permutation, that is, `order[i]` is the index of the shuffled
+// choice that belongs in position `i`.
+func shuffle(choices []string) ([]string, []int) {
+	perm := rand.Perm(len(choices))
+	shuffled := make([]string, len(choices))
+	order := make([]int, len(choices))
+	for i, j := range perm {
+		shuffled[j] = choices[i]
+		order[i] = j
+	}
+	return shuffled, order
+}
+
+// Scores a player's guess, returning the points earned and whether
+// the guess was entirely correct. Note that a partially correct
+// ordering can earn points without being correct.
+//
+// If the guess is a float64, then it's a bitmap (or the guess of
+// a numeric question), and if it's a list, then it's the permutation
+// of an ordering question.
+func (q *CurrentQuestion) Evaluate(guess any) (int, bool) {
+	if q.Kind == KindNumber {
+		if f, ok := guess.(float64); ok && f == q.Answer {
+			return q.Weight, true
+		}
+		return 0, false
+	}
+	if q.Kind == KindText {
+		answer, _ := q.Answer.(string)
+		if s, ok := guess.(string); ok && strings.EqualFold(strings.TrimSpace(s), answer) {
+			return q.Weight, true
+		}
+		return 0, false
+	}
+	var res bool
+	switch vv := guess.(type) {
+	case float64:
+		answer, ok := q.Answer.(uint16)
+		if !ok {
+			return 0, false
+		}
+		// Compensate for the bit that we set for the multi-answer
+		// multiple choice question.
+		if answer>>15 == 1 {
+			res = float64(answer) == (1<<15)+vv
+		} else {
+			res = float64(answer) == vv
+		}
+	case string:
+		res = q.Answer == vv
+	case []any:
+		if q.Kind != KindOrder || len(vv) != len(q.Order) {
+			return 0, false
+		}
+		matches := 0
+		for i, v := range vv {
+			if f, ok := v.(float64); ok && int(f) == q.Order[i] {
+				matches++
+			}
+		}
+		if matches == len(q.Order) {
+			return q.Weight, true
+		}
+		if q.Scoring == ScorePartial {
+			return q.Weight * matches / len(q.Order), false
+		}
+		return 0, false
+	}
+	if res {
+		return q.Weight, true
+	}
+	return 0, false
+}
+
+// The question as the players (and displays) are shown it, which leaves
+// out the answer until the question is revealed. A multiple choice
+// question only says whether more than one of its choices can be picked.
+// See [Reveal].
+type ShownQuestion struct {
+	CurrentQuestion
+	Multi bool `json:"multi,omitempty"`
+}
+
+func (q CurrentQuestion) shown() ShownQuestion {
+	shown := ShownQuestion{CurrentQuestion: q}
+	if bitmap, ok := q.Answer.(uint16); ok {
+		shown.Multi = bitmap>>15 == 1
+	}
+	shown.Answer = nil
+	return shown
+}
+
+// The correct answer as it should be shown to a player.
+func (q *CurrentQuestion) CorrectAnswer() string {
+	if q.Kind == KindOrder {
+		t := make([]string, len(q.Order))
+		for i, j := range q.Order {
+			t[i] = q.Choices[j]
+		}
+		return strings.Join(t, " > ")
+	}
+	if answer, ok := q.Answer.(uint16); ok {
+		return strings.Join(getItemFromLog(q.Choices, answer), ",")
+	}
+	return fmt.Sprintf("%v", q.Answer)
+}
+
+// Translates a player's guess into the choices it represents.
+func (q *CurrentQuestion) FormatGuess(guess any) string {
+	switch vv := guess.(type) {
+	case float64:
+		if q.Kind == KindNumber {
+			return strconv.FormatFloat(vv, 'f', -1, 64)
+		}
+		if vv <= 0 {
+			return ""
+		}
+		return strings.Join(getItemFromLog(q.Choices, uint16(vv)), ",")
+	case []any:
+		t := []string{}
+		for _, v := range vv {
+			if f, ok := v.(float64); ok && int(f) >= 0 && int(f) < len(q.Choices) {
+				t = append(t, q.Choices[int(f)])
+			}
+		}
+		return strings.Join(t, " > ")
+	}
+	return fmt.Sprintf("%v", guess)
+}
+
+// Every player's result, sorted by name.
+func (q *CurrentQuestion) Results() []Result {
+	results := make([]Result, 0, len(q.Responses))
+	for name, response := range q.Responses {
+		results = append(results, Result{
+			Name:    name,
+			Guess:   q.FormatGuess(response.Guess),
+			Correct: response.Correct,
+			Points:  response.Points,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results
+}
+
+// Counts how many players picked each choice. For a multiple choice
+// question every choice is counted, even if nobody picked it, and
+// for any other question the distinct guesses are counted.
+func (q *CurrentQuestion) Distribution() map[string]int {
+	distribution := make(map[string]int)
+	if _, ok := q.Answer.(uint16); ok {
+		for _, choice := range q.Choices {
+			distribution[choice] = 0
+		}
+	}
+	for _, response := range q.Responses {
+		if f, ok := response.Guess.(float64); ok && q.Kind == "" {
+			if f > 0 {
+				for _, choice := range getItemFromLog(q.Choices, uint16(f)) {
+					distribution[choice]++
+				}
+			}
+			continue
+		}
+		distribution[q.FormatGuess(response.Guess)]++
+	}
+	return distribution
+}
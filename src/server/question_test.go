@@ -0,0 +1,75 @@
+package server
+
+import "testing"
+
+// The guess of an ordering question lists the (shuffled) choices'
+// indices in the order that the player put them.
+func orderGuess(order ...int) []any {
+	guess := make([]any, len(order))
+	for i, j := range order {
+		guess[i] = float64(j)
+	}
+	return guess
+}
+
+func TestEvaluateOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		scoring string
+		weight  int
+		guess   any
+		points  int
+		correct bool
+	}{
+		{"exact and correct", ScoreExact, 40, orderGuess(3, 1, 0, 2), 40, true},
+		{"exact with two in place", ScoreExact, 40, orderGuess(3, 1, 2, 0), 0, false},
+		{"partial and correct", ScorePartial, 40, orderGuess(3, 1, 0, 2), 40, true},
+		{"partial with two in place", ScorePartial, 40, orderGuess(3, 1, 2, 0), 20, false},
+		{"partial with one in place", ScorePartial, 50, orderGuess(3, 0, 2, 1), 12, false},
+		{"partial with none in place", ScorePartial, 40, orderGuess(0, 2, 3, 1), 0, false},
+		{"too few choices", ScorePartial, 40, orderGuess(3, 1, 0), 0, false},
+		{"too many choices", ScorePartial, 40, orderGuess(3, 1, 0, 2, 4), 0, false},
+		{"a choice that isn't a number", ScorePartial, 40, []any{float64(3), "1", float64(0), float64(2)}, 30, false},
+		{"not a list", ScorePartial, 40, float64(3), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := CurrentQuestion{
+				Kind:    KindOrder,
+				Scoring: tt.scoring,
+				Weight:  tt.weight,
+				Choices: []string{"horse", "cat", "whale", "ant"},
+				Order:   []int{3, 1, 0, 2},
+			}
+			points, correct := q.Evaluate(tt.guess)
+			if points != tt.points || correct != tt.correct {
+				t.Errorf("got %d points and correct = %v, want %d and %v", points, correct, tt.points, tt.correct)
+			}
+		})
+	}
+}
+
+// However the choices are shuffled, the correct permutation puts them
+// back in the order that the deck lists them.
+func TestParseOrderQuestion(t *testing.T) {
+	tests := []struct {
+		line    string
+		scoring string
+	}{
+		{"Order them by size|40|order|ant|cat|horse|whale", ScoreExact},
+		{"Order them by size|40|order:partial|ant|cat|horse|whale", ScorePartial},
+	}
+	for _, tt := range tests {
+		q, err := parseQuestion(tt.line)
+		mustDo(t, err)
+		if q.Kind != KindOrder || q.Scoring != tt.scoring {
+			t.Errorf("%q is a %q question scored %q, want %q scored %q", tt.line, q.Kind, q.Scoring, KindOrder, tt.scoring)
+		}
+		if answer := q.CorrectAnswer(); answer != "ant > cat > horse > whale" {
+			t.Errorf("%q has the answer %q", tt.line, answer)
+		}
+		if points, correct := q.Evaluate(orderGuess(q.Order...)); points != 40 || !correct {
+			t.Errorf("the correct order of %q got %d points and correct = %v", tt.line, points, correct)
+		}
+	}
+}
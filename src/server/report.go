@@ -0,0 +1,146 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// The formats of the post-game report.
+const (
+	ReportCSV  = "csv"
+	ReportHTML = "html"
+	ReportJSON = "json"
+)
+
+// A transcript of the whole game: every question that was asked,
+// how each player answered it, its statistics, the final scoreboard
+// and the host's audit log.
+type Report struct {
+	Game       string           `json:"game"`
+	Generated  time.Time        `json:"generated"`
+	Questions  []ReportQuestion `json:"questions"`
+	Scoreboard Scoreboard       `json:"scoreboard"`
+	Audit      []AuditEntry     `json:"audit"`
+}
+
+// Only the questions that have been closed are in the report, since
+// the answer and results of the open question would give it away.
+type ReportQuestion struct {
+	Number   int            `json:"number"`
+	Round    int            `json:"round,omitempty"`
+	Category string         `json:"category,omitempty"`
+	Question string         `json:"question"`
+	Answer   string         `json:"answer"`
+	Weight   int            `json:"weight"`
+	Results  []Result       `json:"results"`
+	Stats    *QuestionStats `json:"stats"`
+}
+
+func newReportQuestion(q *CurrentQuestion) ReportQuestion {
+	return ReportQuestion{
+		Number:   q.Number,
+		Round:    q.Round,
+		Category: q.Category,
+		Question: q.Question,
+		Answer:   q.CorrectAnswer(),
+		Weight:   q.Weight,
+		Results:  q.Results(),
+		Stats:    q.Stats,
+	}
+}
+
+// The caller must hold the game's lock.
+func (g *Game) Report() *Report {
+	report := &Report{
+		Game:       g.Name,
+		Generated:  time.Now().UTC(),
+		Questions:  make([]ReportQuestion, 0, len(g.Questions)),
+		Scoreboard: g.GetScoreboard(),
+		Audit:      append(make([]AuditEntry, 0, len(g.Audit)), g.Audit...),
+	}
+	for i := range g.Questions {
+		report.Questions = append(report.Questions, newReportQuestion(&g.Questions[i]))
+	}
+	return report
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(r)
+}
+
+// The CSV report has four tables, each with its own header and
+// separated by an empty line: the questions and their statistics,
+// every player's answers, the final scoreboard and the audit log,
+// whose before and after values are JSON.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"number", "question", "answer", "weight", "responses", "correct", "percent_correct", "median_time", "fastest"})
+	for _, q := range r.Questions {
+		cw.Write([]string{
+			strconv.Itoa(q.Number),
+			q.Question,
+			q.Answer,
+			strconv.Itoa(q.Weight),
+			strconv.Itoa(q.Stats.Responses),
+			strconv.Itoa(q.Stats.Correct),
+			fmt.Sprintf("%.1f", q.Stats.PercentCorrect),
+			fmt.Sprintf("%.3f", q.Stats.MedianTime),
+			q.Stats.Fastest,
+		})
+	}
+
+	cw.Write(nil)
+	cw.Write([]string{"number", "player", "guess", "correct", "points"})
+	for _, q := range r.Questions {
+		for _, result := range q.Results {
+			cw.Write([]string{
+				strconv.Itoa(q.Number),
+				result.Name,
+				result.Guess,
+				strconv.FormatBool(result.Correct),
+				strconv.Itoa(result.Points),
+			})
+		}
+	}
+
+	cw.Write(nil)
+	cw.Write([]string{"rank", "player", "score"})
+	for _, score := range r.Scoreboard {
+		cw.Write([]string{
+			strconv.Itoa(score.Rank),
+			score.Name,
+			strconv.Itoa(score.Score),
+		})
+	}
+
+	cw.Write(nil)
+	cw.Write([]string{"seq", "time", "actor", "address", "action", "target", "before", "after"})
+	for _, entry := range r.Audit {
+		before, _ := json.Marshal(entry.Before)
+		after, _ := json.Marshal(entry.After)
+		cw.Write([]string{
+			strconv.Itoa(entry.Seq),
+			entry.Time.UTC().Format(time.RFC3339),
+			entry.Actor,
+			entry.Address,
+			entry.Action,
+			entry.Target,
+			string(before),
+			string(after),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// The printable report is rendered by the `report` template.
+// See templates/report.gohtml.
+func (s *SocketServer) WriteReportHTML(w io.Writer, r *Report) error {
+	return s.Tpl.ExecuteTemplate(w, "report", r)
+}
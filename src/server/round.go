@@ -0,0 +1,150 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A game can be split into rounds, each with a title and category.
+// Every point earned in a round is multiplied by its `Multiplier`,
+// and the points of each player are kept as a subtotal.
+//
+// Every question of the `Final` round (double or nothing) is a
+// wager question. See [Game.PlaceWager].
+//
+// Every question of a `Buzzer` round is answered by the first
+// player to buzz in. See [BuzzQueue].
+type Round struct {
+	Number     int            `json:"number"`
+	Title      string         `json:"title"`
+	Category   string         `json:"category,omitempty"`
+	Multiplier float64        `json:"multiplier"`
+	Final      bool           `json:"final,omitempty"`
+	Buzzer     bool           `json:"buzzer,omitempty"`
+	Asked      int            `json:"asked"`
+	Subtotals  map[string]int `json:"-"`
+}
+
+// What is published when a new round begins, which the players
+// see as an intermission screen.
+type Intermission struct {
+	Round      *Round     `json:"round"`
+	Rounds     []*Round   `json:"rounds"`
+	Scoreboard Scoreboard `json:"scoreboard"`
+}
+
+// Parses a round header, which has the format:
+//
+//	Title|category|multiplier|final
+//
+// Only the title is required. The multiplier defaults to one, and
+// the last field is the round's mode: either the keyword `final`
+// for the double or nothing final round or `buzzer` for a buzzer
+// round.
+//
+//	Round Two|The Sixties|2
+//	Lightning Round|Motown||buzzer
+//	Final Round|One-Hit Wonders||final
+func parseRound(s string) (*Round, error) {
+	l := strings.Split(s, "|")
+	var category, mode string
+	var multiplier float64
+	if len(l) > 1 {
+		category = l[1]
+	}
+	if len(l) > 2 && strings.TrimSpace(l[2]) != "" {
+		m, err := strconv.ParseFloat(strings.TrimSpace(l[2]), 64)
+		if err != nil {
+			return nil, err
+		}
+		if m <= 0 {
+			return nil, errors.New("the multiplier must be greater than zero")
+		}
+		multiplier = m
+	}
+	if len(l) > 3 {
+		mode = l[3]
+	}
+	return newRound(l[0], category, multiplier, mode)
+}
+
+// A multiplier of zero is the default of one. See [parseRound].
+func newRound(title, category string, multiplier float64, mode string) (*Round, error) {
+	round := &Round{
+		Title:      strings.TrimSpace(title),
+		Category:   strings.TrimSpace(category),
+		Multiplier: 1,
+		Subtotals:  make(map[string]int),
+	}
+	if round.Title == "" {
+		return nil, errors.New("a round needs a title")
+	}
+	if multiplier < 0 {
+		return nil, errors.New("the multiplier must be greater than zero")
+	}
+	if multiplier > 0 {
+		round.Multiplier = multiplier
+	}
+	switch strings.TrimSpace(mode) {
+	case "":
+	case "final":
+		round.Final = true
+	case "buzzer":
+		round.Buzzer = true
+	default:
+		return nil, fmt.Errorf("unknown round mode `%s`", mode)
+	}
+	return round, nil
+}
+
+// The current round, if the game has been split into rounds.
+func (g *Game) CurrentRound() *Round {
+	if len(g.Rounds) == 0 {
+		return nil
+	}
+	return g.Rounds[len(g.Rounds)-1]
+}
+
+// Begins a new round and returns the intermission that's shown
+// to the players until the round's first question is asked.
+func (g *Game) StartRound(round *Round) (*Intermission, error) {
+	err := g.emit(EventRoundBegun, RoundBegun{
+		Title:      round.Title,
+		Category:   round.Category,
+		Multiplier: round.Multiplier,
+		Final:      round.Final,
+		Buzzer:     round.Buzzer,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Intermission{
+		Round:      g.CurrentRound(),
+		Rounds:     g.Rounds,
+		Scoreboard: g.GetScoreboard(),
+	}, nil
+}
+
+func (g *Game) beginRound(d *RoundBegun) {
+	g.Rounds = append(g.Rounds, &Round{
+		Number:     len(g.Rounds) + 1,
+		Title:      d.Title,
+		Category:   d.Category,
+		Multiplier: d.Multiplier,
+		Final:      d.Final,
+		Buzzer:     d.Buzzer,
+		Subtotals:  make(map[string]int),
+	})
+}
+
+// Adjusts the points of a guess for the current round.
+func (g *Game) roundPoints(points int) int {
+	round := g.CurrentRound()
+	if round == nil {
+		return points
+	}
+	return int(math.Round(float64(points) * round.Multiplier))
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRound(t *testing.T) {
+	tests := []struct {
+		line string
+		want *Round
+		err  bool
+	}{
+		{
+			line: "Round One",
+			want: &Round{Title: "Round One", Multiplier: 1},
+		},
+		{
+			line: "Round Two|The Sixties|2",
+			want: &Round{Title: "Round Two", Category: "The Sixties", Multiplier: 2},
+		},
+		{
+			line: "Lightning Round|Motown||buzzer",
+			want: &Round{Title: "Lightning Round", Category: "Motown", Multiplier: 1, Buzzer: true},
+		},
+		{
+			line: "Final Round|One-Hit Wonders||final",
+			want: &Round{Title: "Final Round", Category: "One-Hit Wonders", Multiplier: 1, Final: true},
+		},
+		{line: "|The Sixties", err: true},
+		{line: "Round Two|The Sixties|two", err: true},
+		{line: "Round Two|The Sixties|0", err: true},
+		{line: "Round Two|The Sixties|-1", err: true},
+		{line: "Round Two|The Sixties|2|sudden", err: true},
+	}
+	for _, tt := range tests {
+		round, err := parseRound(tt.line)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q parsed, want an error", tt.line)
+			}
+			continue
+		}
+		mustDo(t, err)
+		tt.want.Subtotals = make(map[string]int)
+		if !reflect.DeepEqual(round, tt.want) {
+			t.Errorf("%q is %+v, want %+v", tt.line, round, tt.want)
+		}
+	}
+}
+
+// The points of every answer in a round are multiplied and rounded
+// to the nearest point, and added to the players' subtotals.
+func TestRoundPoints(t *testing.T) {
+	tests := []struct {
+		name       string
+		multiplier float64
+		weight     string
+		want       map[string]int
+	}{
+		{"no multiplier", 1, "15", map[string]int{"alice": 15, "bob": 0}},
+		{"doubled", 2, "15", map[string]int{"alice": 30, "bob": 0}},
+		{"rounded up", 1.5, "15", map[string]int{"alice": 23, "bob": 0}},
+		{"halved and rounded up", 0.5, "15", map[string]int{"alice": 8, "bob": 0}},
+		{"rounded down", 0.3, "15", map[string]int{"alice": 5, "bob": 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, g := newTestGame(t, nil)
+			join(t, g, "alice", "bob")
+			mustDo(t, s.SetState(g, StateRunning))
+			mustDo(t, s.StartRound(g, &Round{Title: "Round Two", Multiplier: tt.multiplier}))
+			ask(t, s, g, "What is the capital of France?|"+tt.weight+"|Paris")
+			guess(t, s, g, "alice", "Paris")
+			guess(t, s, g, "bob", "Lyon")
+
+			checkScores(t, g, AuditScores{Players: tt.want})
+			if subtotals := g.CurrentRound().Subtotals; !reflect.DeepEqual(subtotals, tt.want) {
+				t.Errorf("got subtotals %v, want %v", subtotals, tt.want)
+			}
+			checkReplay(t, g)
+		})
+	}
+}
+
+// Every question of the final round is double or nothing, whatever
+// the round's multiplier.
+func TestFinalRound(t *testing.T) {
+	s, g := newTestGame(t, nil)
+	join(t, g, "alice", "bob", "carl")
+	mustDo(t, s.SetState(g, StateRunning))
+	addPoints(t, s, g, "alice", 100)
+	addPoints(t, s, g, "bob", 50)
+	mustDo(t, s.StartRound(g, &Round{Title: "Final Round", Multiplier: 2, Final: true}))
+	ask(t, s, g, "In what year was Woodstock?|20|number:1969")
+	if !g.CurrentQuestion.Wager || g.CurrentQuestion.Phase != PhaseWager {
+		t.Fatalf("the question of the final round is a wager = %v in phase %q", g.CurrentQuestion.Wager, g.CurrentQuestion.Phase)
+	}
+
+	wagers := []struct {
+		name   string
+		amount int
+		err    bool
+	}{
+		{"alice", 101, true},
+		{"alice", -1, true},
+		{"alice", 60, false},
+		{"bob", 50, false},
+		{"carl", 10, true},
+		{"carl", 0, false},
+	}
+	for _, w := range wagers {
+		err := g.PlaceWager(player(t, g, w.name), w.amount)
+		if (err != nil) != w.err {
+			t.Errorf("`%s` wagered %d with the error %v, want an error = %v", w.name, w.amount, err, w.err)
+		}
+	}
+	if !g.ReadyToShow() {
+		t.Fatal("everyone has wagered, but the question isn't ready to be shown")
+	}
+	mustDo(t, s.Show(g))
+	guess(t, s, g, "alice", float64(1969))
+	guess(t, s, g, "bob", float64(1970))
+	guess(t, s, g, "carl", float64(1969))
+
+	checkScores(t, g, AuditScores{Players: map[string]int{"alice": 160, "bob": 0, "carl": 0}})
+	want := map[string]int{"alice": 60, "bob": -50, "carl": 0}
+	if subtotals := g.CurrentRound().Subtotals; !reflect.DeepEqual(subtotals, want) {
+		t.Errorf("got subtotals %v, want %v", subtotals, want)
+	}
+	checkReplay(t, g)
+}
@@ -6,10 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"text/template"
+	"time"
 
+	"github.com/btoll/trivial/src/metrics"
 	"github.com/btoll/trivial/src/middleware"
+	"github.com/btoll/trivial/src/profile"
+	"github.com/btoll/trivial/src/webhook"
 	"golang.org/x/net/websocket"
 )
 
@@ -18,15 +23,26 @@ var templateFiles embed.FS
 
 // A socket server instance is set up to handle
 // multiple (concurrent) games.
+//
+// `Deck` is the directory that contains the media files
+// that questions can reference. See [parseMedia].
 type SocketServer struct {
 	Location URL
 	Games    map[string]*Game
+	Deck     string
 	Tpl      *template.Template
 	Mux      *http.ServeMux
+	// Players' profiles are only kept when a store is given.
+	Profiles *profile.Store
+	// The game's events are only sent to webhooks when they're given.
+	Webhooks *webhook.Dispatcher
+	// Where and since when the server has been listening.
+	address   string
+	listening time.Time
 }
 
 func NewSocketServer(url URL) *SocketServer {
-	return &SocketServer{
+	s := &SocketServer{
 		Location: url,
 		Games:    make(map[string]*Game),
 		// In templates/, the `_base.html` file **must** be the first file!!
@@ -35,6 +51,8 @@ func NewSocketServer(url URL) *SocketServer {
 		Tpl: template.Must(template.ParseFS(templateFiles, "templates/*.gohtml")),
 		Mux: http.NewServeMux(),
 	}
+	s.registerMetrics()
+	return s
 }
 
 type Socket struct {
@@ -109,42 +127,304 @@ func (s *SocketServer) GetPlayerBySocket(socket *websocket.Conn) (*Player, *Game
 	return nil, nil, errors.New("cannot get player from socket")
 }
 
-// Notify a single player of an event.
+// Notify a single player of an event. A player that guesses over HTTP
+// has no socket, and instead follows the game's feed (see [Feed]),
+// which doesn't have the events that are only for them.
 func (s *SocketServer) Message(socket *websocket.Conn, msg ServerMessage) error {
+	if socket == nil {
+		return nil
+	}
 	b, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
-	_, err = socket.Write(b)
+	return write(socket, msg.Type, b)
+}
+
+// Sends a message that's only for the player. A player that isn't
+// connected by websocket, because they guess over HTTP, is sent it in
+// the game's feed instead, which only they can read. See [Feed].
+func (s *SocketServer) tell(game *Game, player *Player, msg ServerMessage) error {
+	if player.Socket != nil {
+		return s.Message(player.Socket, msg)
+	}
+	b, err := json.Marshal(msg)
 	if err != nil {
-		return fmt.Errorf("websocket write error: %v", err)
+		return err
 	}
+	game.Feed.publishTo(player.Name, msg.Type, b)
 	return nil
 }
 
-// Notifies every player of an event.
+// Notifies every player (and display) of an event.
 func (s *SocketServer) Publish(game *Game, msg ServerMessage) error {
 	b, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
 	for _, player := range game.Players {
+		if player.Socket == nil {
+			continue
+		}
 		go func(player Player) {
-			if _, err := player.Socket.Write(b); err != nil {
-				fmt.Println("websocket write error:", err)
+			if err := write(player.Socket, msg.Type, b); err != nil {
+				fmt.Println(err)
 			}
 		}(*player)
 	}
+	return s.PublishDisplays(game, msg)
+}
+
+// Sends a new question to every player. If the previous question
+// is still open, its results are revealed first. A wager question
+// only shows its category until every player has placed a wager.
+// The caller must hold the game's lock.
+func (s *SocketServer) Ask(game *Game, question CurrentQuestion) error {
+	if game.IsOpen() {
+		if err := s.Reveal(game); err != nil {
+			return err
+		}
+	}
+	question.Number = game.CurrentQuestion.Number + 1
+	question.Policy = game.AnswerPolicy
+	if round := game.CurrentRound(); round != nil {
+		question.Round = round.Number
+		if question.Category == "" {
+			question.Category = round.Category
+		}
+		// Every question in the final round is double or nothing,
+		// but the sudden-death question is the same in any round.
+		if question.Contenders == nil {
+			question.Wager = question.Wager || round.Final
+			question.Buzzer = round.Buzzer
+		}
+	}
+	if err := game.emit(EventQuestionAsked, askedQuestion(question)); err != nil {
+		return err
+	}
+	if question.Wager {
+		return s.Publish(game, ServerMessage{
+			Type: "wager",
+			Data: Wagering{
+				Number:   question.Number,
+				Round:    question.Round,
+				Category: question.Category,
+			},
+		})
+	}
+	return s.Show(game)
+}
+
+// The sudden-death question is asked only once, and a question only
+// counts towards the round that it was asked in.
+func (g *Game) ask(question CurrentQuestion) {
+	if round := g.CurrentRound(); round != nil && round.Number == question.Round {
+		round.Asked++
+	}
+	question.Responses = make(map[string]*Response)
+	if question.Wager {
+		question.Phase = PhaseWager
+		question.Wagers = make(map[string]int)
+	}
+	if question.Contenders != nil {
+		g.SuddenDeath = nil
+	}
+	g.CurrentQuestion = question
+}
+
+// Shows the current question to every player, which, for a wager
+// question, ends the wagering. When the game has a time limit, the
+// question is closed when the time is up. The buzzers of a buzzer
+// question are armed shortly after it's shown.
+// The caller must hold the game's lock.
+func (s *SocketServer) Show(game *Game) error {
+	question := &game.CurrentQuestion
+	if !game.IsOpen() || question.Phase == PhaseOpen {
+		return errors.New("There is no question to show")
+	}
+	if err := game.emit(EventQuestionShown, QuestionShown{Number: question.Number}); err != nil {
+		return err
+	}
+	if game.TimeLimit > 0 {
+		s.startTimer(game, game.TimeLimit)
+	}
+
+	if question.Buzzer {
+		s.armBuzzer(game)
+	}
+
+	b, err := json.Marshal(question.shown())
+	if err != nil {
+		return err
+	}
+	s.fireQuestion(game)
+	return s.Publish(game, ServerMessage{
+		Type: "question",
+		Data: string(b),
+	})
+}
+
+func (g *Game) show(t time.Time) {
+	q := &g.CurrentQuestion
+	q.Phase = PhaseOpen
+	q.Asked = t
+	if g.TimeLimit > 0 {
+		q.Deadline = t.Add(g.TimeLimit)
+		q.TimeLimit = int(g.TimeLimit.Seconds())
+	}
+}
+
+// Reveals the current question when the time is up.
+// The caller must hold the game's lock.
+func (s *SocketServer) startTimer(game *Game, d time.Duration) {
+	number := game.CurrentQuestion.Number
+	game.timer = newGameTimer(d, func() {
+		game.mu.Lock()
+		defer game.mu.Unlock()
+		// The question may have been closed (and another asked)
+		// before the timer fired.
+		if game.IsOpen() && game.CurrentQuestion.Number == number {
+			if err := s.Reveal(game); err != nil {
+				fmt.Println(err)
+			}
+		}
+	})
+}
+
+// Records a player's guess, which is held until the question is
+// closed, at which point everyone learns the results at the same
+// time. The host's watchers see it straight away, and the displays
+// see how many have answered. The player is told that it has been
+// recorded, which is also returned. See [SocketServer.Reveal].
+// The caller must hold the game's lock.
+func (s *SocketServer) Guess(game *Game, player *Player, guess any) (string, error) {
+	response, err := game.Respond(player, guess)
+	if err != nil {
+		return "", err
+	}
+	observeResponse(game, response)
+	playerGuess := game.CurrentQuestion.FormatGuess(guess)
+	notice := fmt.Sprintf("Your answer %s has been recorded", playerGuess)
+	err = s.Message(player.Socket, ServerMessage{
+		Type: "notify_player",
+		Data: notice,
+	})
+	if err != nil {
+		return "", err
+	}
+	err = s.PublishDisplays(game, ServerMessage{
+		Type: "answered",
+		Data: game.GetAnswered(),
+	})
+	if err != nil {
+		return "", err
+	}
+	err = s.PublishWatchers(game, ServerMessage{
+		Type: "guess",
+		Data: newGuess(game, player.Name, playerGuess, response),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Log the player's result.
+	if response.Correct {
+		fmt.Printf("%s correctly guessed %s, %d current points\n",
+			player.Name,
+			playerGuess,
+			player.Score)
+	} else {
+		fmt.Printf("%s incorrectly guessed %s, %d current points\n",
+			player.Name,
+			playerGuess,
+			player.Score)
+	}
+
+	// If everyone has answered, reveal the results.
+	// Note that this only counts the distinct players who are still
+	// in the game.
+	// In a buzzer round, a wrong answer passes the buzz on.
+	if game.CurrentQuestion.Buzzer && !response.Correct {
+		if err := s.PassBuzz(game); err != nil {
+			fmt.Println(err)
+		}
+	} else if game.ReadyToClose() {
+		if err := s.Reveal(game); err != nil {
+			fmt.Println(err)
+		}
+	}
+	return notice, nil
+}
+
+// Closes the current question and publishes the correct answer,
+// every player's result, the answer distribution and the updated
+// scoreboard as a single `reveal` event, followed by the question's
+// statistics as a `stats` event.
+// The caller must hold the game's lock.
+func (s *SocketServer) Reveal(game *Game) error {
+	reveal, err := game.Close()
+	if err != nil {
+		return err
+	}
+	err = s.Publish(game, ServerMessage{
+		Type: "reveal",
+		Data: reveal,
+	})
+	if err != nil {
+		return err
+	}
+	stats := game.Questions[len(game.Questions)-1].Stats
+	s.fire(game, webhook.QuestionClosed, ClosedQuestion{reveal, stats})
+	err = s.Publish(game, ServerMessage{
+		Type: "stats",
+		Data: stats,
+	})
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(reveal.Scoreboard)
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(string(b))
+	if game.CurrentQuestion.Contenders != nil && game.State != StateFinished {
+		return s.SetState(game, StateFinished)
+	}
 	return nil
 }
 
+// Begins a new round and shows the intermission screen to every
+// player. If a question is still open, its results are revealed first.
+// The caller must hold the game's lock.
+func (s *SocketServer) StartRound(game *Game, round *Round) error {
+	if game.IsOpen() {
+		if err := s.Reveal(game); err != nil {
+			return err
+		}
+	}
+	intermission, err := game.StartRound(round)
+	if err != nil {
+		return err
+	}
+	return s.Publish(game, ServerMessage{
+		Type: "intermission",
+		Data: intermission,
+	})
+}
+
 func (s *SocketServer) RegisterAndStartGame(game *Game) {
 	s.RegisterGame(game)
 	s.StartGame(game)
 }
 
 // Registers a new game. A socket server can host multiple games.
+// A new game's settings are the first event in its journal.
 func (s *SocketServer) RegisterGame(game *Game) {
+	if len(game.Events) == 0 {
+		if err := game.emit(EventGameCreated, game.settings()); err != nil {
+			fmt.Println(err)
+		}
+	}
 	s.Games[game.Key.Key] = game
 }
 
@@ -153,14 +433,47 @@ func (s *SocketServer) RegisterGame(game *Game) {
 func (s *SocketServer) StartGame(game *Game) {
 	s.Mux.Handle("/ws", websocket.Handler(s.DefaultHandler))
 	s.Mux.HandleFunc("/", s.BaseHandler)
+	s.Mux.HandleFunc(APIPrefix+"/", s.APIHandler)
+	s.Mux.HandleFunc("/audit", s.AuditHandler)
+	s.Mux.HandleFunc("/debug/replay", s.ReplayHandler)
+	s.Mux.HandleFunc("/debug/state", s.DebugStateHandler)
 	s.Mux.HandleFunc("/health", s.HealthHandler)
+	s.Mux.HandleFunc("/healthz", s.HealthzHandler)
+	s.Mux.HandleFunc("/display", s.DisplayHandler)
+	s.Mux.HandleFunc("/events", s.EventsHandler)
+	s.Mux.HandleFunc("/events/poll", s.PollHandler)
+	s.Mux.HandleFunc("/finish", s.FinishHandler)
+	s.Mux.HandleFunc("/guess", s.GuessHandler)
+	s.Mux.HandleFunc("/journal", s.JournalHandler)
 	s.Mux.HandleFunc("/kill", s.KillHandler)
+	s.Mux.HandleFunc("/media/", s.MediaHandler)
+	s.Mux.Handle("/metrics", metrics.Handler())
 	s.Mux.HandleFunc("/message", s.MessageHandler)
 	s.Mux.HandleFunc("/notify", s.NotifyHandler)
+	s.Mux.HandleFunc("/openapi.json", s.OpenAPIHandler)
+	s.Mux.HandleFunc("/pause", s.PauseHandler)
 	s.Mux.HandleFunc("/query", s.QueryHandler)
+	s.Mux.HandleFunc("/readyz", s.ReadyzHandler)
+	s.Mux.HandleFunc("/questions/", s.QuestionStatsHandler)
+	s.Mux.HandleFunc("/report", s.ReportHandler)
 	s.Mux.HandleFunc("/reset", s.ResetHandler)
+	s.Mux.HandleFunc("/restore", s.RestoreHandler)
+	s.Mux.HandleFunc("/resume", s.ResumeHandler)
+	s.Mux.HandleFunc("/reveal", s.RevealHandler)
+	s.Mux.HandleFunc("/round", s.RoundHandler)
 	s.Mux.HandleFunc("/scoreboard", s.ScoreboardHandler)
+	s.Mux.HandleFunc("/scoreboard/history", s.ScoreboardHistoryHandler)
+	s.Mux.HandleFunc("/season", s.SeasonHandler)
+	s.Mux.HandleFunc("/show", s.ShowHandler)
+	s.Mux.HandleFunc("/start", s.StartHandler)
+	s.Mux.HandleFunc("/undo", s.UndoHandler)
 	s.Mux.HandleFunc("/update_score", s.UpdateScoreHandler)
 	//	log.Fatal(http.ListenAndServe(":3000", middleware.NewLogger(NewAuthenticator(&game.Key, s.Mux))))
-	log.Fatal(http.ListenAndServeTLS(":3000", "cert.pem", "key.pem", middleware.NewLogger(middleware.NewAuthenticator(&game.Key, s.Mux))))
+	listener, err := net.Listen("tcp", ":3000")
+	if err != nil {
+		log.Fatal(err)
+	}
+	s.address = listener.Addr().String()
+	s.listening = time.Now()
+	log.Fatal(http.ServeTLS(listener, middleware.NewLogger(middleware.NewMetrics(s.Mux, middleware.NewAuthenticator(&game.Key, s.Mux))), "cert.pem", "key.pem"))
 }
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The lifecycle of a game. The players gather in the lobby until
+// the host starts the game, which can then be paused and resumed
+// any number of times. Once the game is finished, its scores are
+// locked.
+const (
+	StateLobby    = "lobby"
+	StateRunning  = "running"
+	StatePaused   = "paused"
+	StateFinished = "finished"
+)
+
+// The states that each state can move to.
+var transitions = map[string][]string{
+	StateLobby:   {StateRunning, StateFinished},
+	StateRunning: {StatePaused, StateFinished},
+	StatePaused:  {StateRunning, StateFinished},
+}
+
+// What is published as a `state` event whenever the game changes
+// state, and sent to each player as they log in. A resumed question
+// has `Remaining` seconds left to answer, and the final scoreboard
+// is given once the game is finished.
+type GameState struct {
+	State      string     `json:"state"`
+	Players    []string   `json:"players"`
+	Remaining  int        `json:"remaining,omitempty"`
+	Scoreboard Scoreboard `json:"scoreboard,omitempty"`
+}
+
+// A timer that can be paused along with the game and resumed
+// with whatever time it had left.
+// The caller must hold the game's lock.
+type gameTimer struct {
+	f         func()
+	deadline  time.Time
+	remaining time.Duration
+	timer     *time.Timer
+}
+
+func newGameTimer(d time.Duration, f func()) *gameTimer {
+	t := &gameTimer{f: f}
+	t.start(d)
+	return t
+}
+
+func (t *gameTimer) start(d time.Duration) {
+	t.deadline = time.Now().Add(d)
+	t.timer = time.AfterFunc(d, t.f)
+}
+
+func (t *gameTimer) Stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	t.remaining = 0
+}
+
+func (t *gameTimer) Pause() {
+	if t.timer != nil && t.timer.Stop() {
+		t.remaining = time.Until(t.deadline)
+	}
+	t.timer = nil
+}
+
+func (t *gameTimer) Resume() {
+	if t.timer == nil && t.remaining > 0 {
+		t.start(t.remaining)
+		t.remaining = 0
+	}
+}
+
+// Only a running game accepts questions, wagers, buzzes and guesses.
+func (g *Game) CheckRunning() error {
+	switch g.State {
+	case StateLobby:
+		return errors.New("The game hasn't started yet")
+	case StatePaused:
+		return errors.New("The game is paused")
+	case StateFinished:
+		return errors.New("The game is over")
+	}
+	return nil
+}
+
+// Moves the game to a new state. Pausing the game freezes the
+// timers of the current question, and resuming it gives them back
+// the time they had left.
+// The caller must hold the game's lock.
+func (g *Game) SetState(state string) error {
+	allowed := false
+	for _, s := range transitions[g.State] {
+		allowed = allowed || s == state
+	}
+	if !allowed {
+		return fmt.Errorf("The game can't go from %s to %s", g.State, state)
+	}
+	return g.emit(EventStateChanged, StateChanged{State: state})
+}
+
+func (g *Game) changeState(state string, t time.Time) {
+	switch {
+	case state == StatePaused:
+		g.paused = t
+		g.pauseTimers()
+	case g.State == StatePaused && state == StateRunning:
+		g.resumeTimers(t.Sub(g.paused))
+	}
+	g.State = state
+}
+
+func (g *Game) pauseTimers() {
+	if g.timer != nil {
+		g.timer.Pause()
+	}
+	if b := g.CurrentQuestion.Buzzes; b != nil && b.timer != nil {
+		b.timer.Pause()
+	}
+}
+
+// Everything that was waiting on the clock is pushed back
+// by how long the game was paused.
+func (g *Game) resumeTimers(paused time.Duration) {
+	q := &g.CurrentQuestion
+	if !q.Deadline.IsZero() {
+		q.Deadline = q.Deadline.Add(paused)
+	}
+	if g.timer != nil {
+		g.timer.Resume()
+	}
+	if b := q.Buzzes; b != nil {
+		b.Armed = b.Armed.Add(paused)
+		if !b.Window.IsZero() {
+			b.Window = b.Window.Add(paused)
+		}
+		for name, lockout := range b.Lockouts {
+			b.Lockouts[name] = lockout.Add(paused)
+		}
+		if b.timer != nil {
+			b.timer.Resume()
+		}
+	}
+}
+
+func (g *Game) GetState() GameState {
+	state := GameState{
+		State:   g.State,
+		Players: make([]string, len(g.Players)),
+	}
+	for i, player := range g.Players {
+		state.Players[i] = player.Name
+	}
+	if g.IsOpen() && !g.CurrentQuestion.Deadline.IsZero() && g.State == StateRunning {
+		state.Remaining = int(time.Until(g.CurrentQuestion.Deadline).Seconds())
+	}
+	if g.State == StateFinished {
+		state.Scoreboard = g.GetScoreboard()
+	}
+	return state
+}
+
+// Moves the game to a new state and lets every player know.
+// A question that is still open when the game is finished is
+// revealed first, so its points still count. If players are then
+// tied for first, the game isn't finished until they've played the
+// sudden-death question. A finished game is added to the players'
+// profiles.
+// The caller must hold the game's lock.
+func (s *SocketServer) SetState(game *Game, state string) error {
+	if state == StateFinished && game.IsOpen() {
+		if err := s.Reveal(game); err != nil {
+			return err
+		}
+		// Revealing the sudden-death question finishes the game.
+		if game.State == StateFinished {
+			return nil
+		}
+	}
+	if state == StateFinished && game.State == StateRunning && game.SuddenDeath != nil {
+		if contenders := game.TiedLeaders(); contenders != nil {
+			return s.askSuddenDeath(game, contenders)
+		}
+	}
+	if err := game.SetState(state); err != nil {
+		return err
+	}
+	if state == StateFinished {
+		s.recordProfiles(game)
+		s.fireFinished(game)
+	}
+	return s.Publish(game, ServerMessage{
+		Type: "state",
+		Data: game.GetState(),
+	})
+}
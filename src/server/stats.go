@@ -0,0 +1,69 @@
+package server
+
+import (
+	"sort"
+	"time"
+)
+
+// How the room answered a question. The answer times are
+// measured (in seconds) from when the question was asked.
+// See [CurrentQuestion.Statistics].
+type QuestionStats struct {
+	Number         int            `json:"number"`
+	Question       string         `json:"question"`
+	Answer         string         `json:"answer"`
+	Responses      int            `json:"responses"`
+	Correct        int            `json:"correct"`
+	PercentCorrect float64        `json:"percentCorrect"`
+	MedianTime     float64        `json:"medianTime"`
+	Fastest        string         `json:"fastest,omitempty"`
+	FastestTime    float64        `json:"fastestTime,omitempty"`
+	Distribution   map[string]int `json:"distribution"`
+}
+
+// Aggregates the responses to the question. This can be called
+// while the question is still open to get the statistics so far.
+func (q *CurrentQuestion) Statistics() *QuestionStats {
+	stats := &QuestionStats{
+		Number:       q.Number,
+		Question:     q.Question,
+		Answer:       q.CorrectAnswer(),
+		Responses:    len(q.Responses),
+		Distribution: q.Distribution(),
+	}
+	times := make([]time.Duration, 0, len(q.Responses))
+	var fastest time.Duration
+	for name, response := range q.Responses {
+		elapsed := response.Received.Sub(q.Asked)
+		times = append(times, elapsed)
+		if !response.Correct {
+			continue
+		}
+		stats.Correct++
+		// Break ties by name so the fastest player doesn't depend
+		// on the order of the map.
+		if stats.Fastest == "" || elapsed < fastest || elapsed == fastest && name < stats.Fastest {
+			stats.Fastest = name
+			fastest = elapsed
+		}
+	}
+	if stats.Responses > 0 {
+		stats.PercentCorrect = float64(stats.Correct) / float64(stats.Responses) * 100
+		stats.MedianTime = median(times).Seconds()
+	}
+	if stats.Fastest != "" {
+		stats.FastestTime = fastest.Seconds()
+	}
+	return stats
+}
+
+func median(times []time.Duration) time.Duration {
+	sort.Slice(times, func(i, j int) bool {
+		return times[i] < times[j]
+	})
+	n := len(times)
+	if n%2 == 1 {
+		return times[n/2]
+	}
+	return (times[n/2-1] + times[n/2]) / 2
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// In teams mode, each team gives a single answer to a question,
+// which is decided by one of these policies:
+//
+//   - captain: the answer of the team's captain (the first player
+//     to join the team, see [Game.replaceCaptain])
+//   - majority: the most common answer of the team's players, with
+//     a tie going to the answer that was given first
+//   - first: the first answer given by any of the team's players
+//
+// An empty policy means the game isn't played in teams.
+const (
+	TeamCaptain  = "captain"
+	TeamMajority = "majority"
+	TeamFirst    = "first"
+)
+
+// A team earns points for its answer as a unit, separate from
+// the points its players earn for their own answers.
+type Team struct {
+	Name    string `json:"name"`
+	Captain string `json:"captain,omitempty"`
+	Score   int    `json:"score"`
+}
+
+// A team's answer to a question, which is revealed along with
+// the results of the players.
+type TeamResult struct {
+	Name    string `json:"name"`
+	Guess   string `json:"guess"`
+	Correct bool   `json:"correct"`
+	Points  int    `json:"points"`
+}
+
+type TeamScoreboard []*TeamScore
+
+func (s TeamScoreboard) Len() int {
+	return len(s)
+}
+
+func (s TeamScoreboard) Less(i, j int) bool {
+	return s[i].Score > s[j].Score
+}
+
+func (s TeamScoreboard) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// The players of a team are its individual drill-down.
+type TeamScore struct {
+	Name    string     `json:"name"`
+	Score   int        `json:"score"`
+	Players Scoreboard `json:"players"`
+}
+
+func (g *Game) GetTeam(name string) (*Team, error) {
+	for _, team := range g.Teams {
+		if strings.EqualFold(name, team.Name) {
+			return team, nil
+		}
+	}
+	return nil, fmt.Errorf("Team `%s` not found.", name)
+}
+
+// The active players of a team.
+func (g *Game) GetTeamPlayers(name string) GamePlayers {
+	players := make(GamePlayers, 0)
+	for _, player := range g.Players {
+		if player.Team == name {
+			players = append(players, player)
+		}
+	}
+	return players
+}
+
+// Chooses the team of a new player, which is the team of their
+// choice or, if they don't choose, the team with the fewest players.
+// There is no team when the game isn't played in teams.
+func (g *Game) chooseTeam(name string) (string, error) {
+	if g.TeamPolicy == "" {
+		return "", nil
+	}
+	if name = strings.TrimSpace(name); name != "" {
+		return name, nil
+	}
+	var team *Team
+	for _, t := range g.Teams {
+		if team == nil || len(g.GetTeamPlayers(t.Name)) < len(g.GetTeamPlayers(team.Name)) {
+			team = t
+		}
+	}
+	if team == nil {
+		return "", errors.New("Choose a team")
+	}
+	return team.Name, nil
+}
+
+// Puts a new player on a team, which is created if it doesn't exist.
+// The first player to join a team is its captain.
+func (g *Game) JoinTeam(player *Player, name string) {
+	team, _ := g.GetTeam(name)
+	if team == nil {
+		team = &Team{Name: name}
+		g.Teams = append(g.Teams, team)
+	}
+	if team.Captain == "" {
+		team.Captain = player.Name
+	}
+	player.Team = team.Name
+}
+
+// When a team's captain is benched, its next active player (in the
+// order that they joined) becomes its captain. A team without any
+// active players has no captain until one of them comes back.
+func (g *Game) replaceCaptain(name string) {
+	team := g.teamOf(name)
+	if team == nil || team.Captain != name {
+		return
+	}
+	team.Captain = ""
+	if players := g.GetTeamPlayers(team.Name); len(players) > 0 {
+		team.Captain = players[0].Name
+	}
+}
+
+func (g *Game) rejoinTeam(name string) {
+	if team := g.teamOf(name); team != nil && team.Captain == "" {
+		team.Captain = name
+	}
+}
+
+// The team of a player (whether active or benched), if they have one.
+func (g *Game) teamOf(name string) *Team {
+	player, _ := g.HasPlayer(name)
+	if player == nil || player.Team == "" {
+		return nil
+	}
+	team, _ := g.GetTeam(player.Team)
+	return team
+}
+
+// The captain of a player's team, which is checked before the player
+// is benched or comes back. See [SocketServer.publishCaptain].
+func (g *Game) captainOf(player *Player) string {
+	if team := g.teamOf(player.Name); team != nil {
+		return team.Captain
+	}
+	return ""
+}
+
+// Tells everyone that the player's team has a new captain, if it
+// isn't `captain` anymore.
+// The caller must hold the game's lock.
+func (s *SocketServer) publishCaptain(game *Game, player *Player, captain string) error {
+	team := game.teamOf(player.Name)
+	if team == nil || team.Captain == captain {
+		return nil
+	}
+	if team.Captain == "" {
+		fmt.Printf("team `%s` has no captain\n", team.Name)
+	} else {
+		fmt.Printf("`%s` is now the captain of team `%s`\n", team.Captain, team.Name)
+	}
+	return s.Publish(game, ServerMessage{
+		Type: "team_captain",
+		Data: team,
+	})
+}
+
+// Decides every team's answer to the current question according
+// to the game's policy and adds its points to the team's score.
+// See [TeamCaptain], [TeamMajority] and [TeamFirst].
+func (g *Game) scoreTeams() []TeamResult {
+	if g.TeamPolicy == "" {
+		return nil
+	}
+	q := &g.CurrentQuestion
+	results := make([]TeamResult, 0, len(g.Teams))
+	for _, team := range g.Teams {
+		var answer *Response
+		// Responses are keyed by the player's name, so look the
+		// players up in both pools in case one has since left.
+		responses := make([]*Response, 0)
+		for name, response := range q.Responses {
+			if player, _ := g.HasPlayer(name); player != nil && player.Team == team.Name {
+				if g.TeamPolicy == TeamCaptain && name == team.Captain {
+					answer = response
+				}
+				responses = append(responses, response)
+			}
+		}
+		sort.Slice(responses, func(i, j int) bool {
+			return responses[i].Received.Before(responses[j].Received)
+		})
+
+		switch g.TeamPolicy {
+		case TeamFirst:
+			if len(responses) > 0 {
+				answer = responses[0]
+			}
+		case TeamMajority:
+			votes := make(map[string]int)
+			for _, response := range responses {
+				votes[q.FormatGuess(response.Guess)]++
+			}
+			for _, response := range responses {
+				if answer == nil || votes[q.FormatGuess(response.Guess)] > votes[q.FormatGuess(answer.Guess)] {
+					answer = response
+				}
+			}
+		}
+
+		if answer == nil {
+			continue
+		}
+		team.Score += answer.Points
+		results = append(results, TeamResult{
+			Name:    team.Name,
+			Guess:   q.FormatGuess(answer.Guess),
+			Correct: answer.Correct,
+			Points:  answer.Points,
+		})
+	}
+	return results
+}
+
+func (g *Game) GetTeamScoreboard() TeamScoreboard {
+	scoreboard := make(TeamScoreboard, len(g.Teams))
+	for i, team := range g.Teams {
+		players := g.GetTeamPlayers(team.Name)
+		drilldown := make(Scoreboard, len(players))
+		for j, player := range players {
+			drilldown[j] = &PlayerScore{
+				Name:  player.Name,
+				Score: player.Score,
+			}
+		}
+		sort.Stable(drilldown)
+		scoreboard[i] = &TeamScore{
+			Name:    team.Name,
+			Score:   team.Score,
+			Players: drilldown,
+		}
+	}
+	sort.Stable(scoreboard)
+	return scoreboard
+}
+
+// Adjusts a team's score, for instance when the host
+// corrects a team's answer.
+func (g *Game) UpdateTeamScore(name string, points int) (int, error) {
+	team, err := g.GetTeam(name)
+	if err != nil {
+		return 0, err
+	}
+	err = g.emit(EventScoreAdjusted, ScoreAdjusted{
+		Team:   team.Name,
+		Points: points,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return team.Score, nil
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Alice joins Red first, so she's its captain. Dave is on Blue and
+// never answers, so Blue gets no result.
+func TestTeamPolicies(t *testing.T) {
+	type answer struct {
+		name  string
+		guess string
+	}
+	tests := []struct {
+		name    string
+		policy  string
+		setup   func(t *testing.T, g *Game)
+		answers []answer
+		want    []TeamResult
+	}{
+		{
+			name:    "the captain's answer",
+			policy:  TeamCaptain,
+			answers: []answer{{"bob", "Paris"}, {"alice", "Lyon"}, {"carl", "Paris"}},
+			want:    []TeamResult{{Name: "Red", Guess: "Lyon"}},
+		},
+		{
+			name:   "the next captain's answer when the captain has left",
+			policy: TeamCaptain,
+			setup: func(t *testing.T, g *Game) {
+				mustDo(t, g.Bench(player(t, g, "alice"), "left"))
+			},
+			answers: []answer{{"carl", "Lyon"}, {"bob", "Paris"}},
+			want:    []TeamResult{{Name: "Red", Guess: "Paris", Correct: true, Points: 10}},
+		},
+		{
+			name:    "no answer from the captain",
+			policy:  TeamCaptain,
+			answers: []answer{{"bob", "Paris"}, {"carl", "Paris"}},
+			want:    []TeamResult{},
+		},
+		{
+			name:    "the most common answer",
+			policy:  TeamMajority,
+			answers: []answer{{"alice", "Lyon"}, {"bob", "Paris"}, {"carl", "Paris"}},
+			want:    []TeamResult{{Name: "Red", Guess: "Paris", Correct: true, Points: 10}},
+		},
+		{
+			name:    "a tie goes to the answer given first",
+			policy:  TeamMajority,
+			answers: []answer{{"bob", "Nice"}, {"alice", "Paris"}, {"carl", "Lyon"}},
+			want:    []TeamResult{{Name: "Red", Guess: "Nice"}},
+		},
+		{
+			name:    "the first answer",
+			policy:  TeamFirst,
+			answers: []answer{{"carl", "Paris"}, {"alice", "Lyon"}, {"bob", "Lyon"}},
+			want:    []TeamResult{{Name: "Red", Guess: "Paris", Correct: true, Points: 10}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, g := newTestGame(t, withTeams(tt.policy, "Red", "Blue"))
+			joinTeam(t, g, "Red", "alice", "bob", "carl")
+			joinTeam(t, g, "Blue", "dave")
+			if tt.setup != nil {
+				tt.setup(t, g)
+			}
+			mustDo(t, s.SetState(g, StateRunning))
+			ask(t, s, g, "What is the capital of France?|10|Paris")
+			for _, a := range tt.answers {
+				guess(t, s, g, a.name, a.guess)
+			}
+			mustDo(t, s.Reveal(g))
+
+			if got := g.revealed.TeamResults; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got team results %+v, want %+v", got, tt.want)
+			}
+			points := 0
+			if len(tt.want) > 0 {
+				points = tt.want[0].Points
+			}
+			if red, _ := g.GetTeam("Red"); red.Score != points {
+				t.Errorf("team `Red` has %d points, want %d", red.Score, points)
+			}
+			checkReplay(t, g)
+		})
+	}
+}
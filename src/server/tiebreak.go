@@ -0,0 +1,184 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// The tie-breakers that decide the order of players with the same
+// score, in the order that the game lists them:
+//
+//   - correct: the player with more correct answers
+//   - time: the player that took less time in total to answer
+//   - last: the player whose last correct answer came first, that
+//     is, who reached their score first
+//
+// Players that are still tied share a rank.
+const (
+	TieCorrect = "correct"
+	TieTime    = "time"
+	TieLast    = "last"
+)
+
+// Parses a comma-separated list of tie-breakers.
+func ParseTieBreakers(s string) ([]string, error) {
+	tieBreakers := make([]string, 0)
+	for _, tb := range strings.Split(s, ",") {
+		switch tb = strings.TrimSpace(tb); tb {
+		case "":
+		case TieCorrect, TieTime, TieLast:
+			tieBreakers = append(tieBreakers, tb)
+		default:
+			return nil, fmt.Errorf("unknown tie-breaker `%s`", tb)
+		}
+	}
+	return tieBreakers, nil
+}
+
+// Parses the sudden-death question, which must be a numeric question.
+// See [parseQuestion].
+func ParseSuddenDeath(s string) (*CurrentQuestion, error) {
+	q, err := parseQuestion(s)
+	if err != nil {
+		return nil, err
+	}
+	if q.Kind != KindNumber || q.Media != "" || q.Wager {
+		return nil, errors.New("the sudden-death question must be a plain numeric question")
+	}
+	return &q, nil
+}
+
+// Adds up what the tie-breakers need from every closed question.
+func (g *Game) tally(scoreboard Scoreboard) {
+	scores := make(map[string]*PlayerScore, len(scoreboard))
+	for _, score := range scoreboard {
+		scores[score.Name] = score
+	}
+	for _, q := range g.Questions {
+		for name, response := range q.Responses {
+			score, ok := scores[name]
+			if !ok {
+				continue
+			}
+			if !q.Asked.IsZero() {
+				score.AnswerTime += response.Received.Sub(q.Asked).Seconds()
+			}
+			if response.Correct {
+				score.Correct++
+				if response.Received.After(score.LastCorrect) {
+					score.LastCorrect = response.Received
+				}
+			}
+		}
+	}
+}
+
+// Returns a negative number when `a` ranks above `b`, a positive
+// number when it ranks below and zero when they're tied.
+func (g *Game) compareScores(a, b *PlayerScore) int {
+	if a.Score != b.Score {
+		return b.Score - a.Score
+	}
+	for _, tb := range g.TieBreakers {
+		switch tb {
+		case TieCorrect:
+			if a.Correct != b.Correct {
+				return b.Correct - a.Correct
+			}
+		case TieTime:
+			if a.AnswerTime != b.AnswerTime {
+				return int(math.Copysign(1, a.AnswerTime-b.AnswerTime))
+			}
+		case TieLast:
+			// A player without a correct answer never reached their score.
+			if !a.LastCorrect.Equal(b.LastCorrect) {
+				if b.LastCorrect.IsZero() || !a.LastCorrect.IsZero() && a.LastCorrect.Before(b.LastCorrect) {
+					return -1
+				}
+				return 1
+			}
+		}
+	}
+	return 0
+}
+
+// Sorts the scoreboard by score and then by the tie-breakers.
+// Players that are still tied are sorted by name, so the order
+// never changes from one scoreboard to the next.
+func (g *Game) sortScoreboard(scoreboard Scoreboard) {
+	g.tally(scoreboard)
+	sort.SliceStable(scoreboard, func(i, j int) bool {
+		if c := g.compareScores(scoreboard[i], scoreboard[j]); c != 0 {
+			return c < 0
+		}
+		return scoreboard[i].Name < scoreboard[j].Name
+	})
+}
+
+// The players that are tied for first, if any, after the
+// tie-breakers.
+func (g *Game) TiedLeaders() []string {
+	leaders := make([]string, 0)
+	for _, score := range g.GetScoreboard() {
+		if score.Rank == 1 {
+			leaders = append(leaders, score.Name)
+		}
+	}
+	if len(leaders) < 2 {
+		return nil
+	}
+	return leaders
+}
+
+// Only the contenders of a sudden-death question can answer it.
+func (q *CurrentQuestion) IsContender(name string) bool {
+	if q.Contenders == nil {
+		return true
+	}
+	for _, contender := range q.Contenders {
+		if contender == name {
+			return true
+		}
+	}
+	return false
+}
+
+// The guesses closest to the answer of a sudden-death question earn
+// its points, and every other guess earns nothing.
+func (q *CurrentQuestion) awardClosest() {
+	answer, _ := q.Answer.(float64)
+	closest := math.Inf(1)
+	for _, response := range q.Responses {
+		if f, ok := response.Guess.(float64); ok {
+			closest = math.Min(closest, math.Abs(f-answer))
+		}
+	}
+	for _, response := range q.Responses {
+		f, ok := response.Guess.(float64)
+		response.Correct = ok && math.Abs(f-answer) == closest
+		response.Points = 0
+		if response.Correct {
+			response.Points = q.Weight
+		}
+	}
+}
+
+// Asks the sudden-death question of the players that are tied for
+// first. The game is finished once it has been revealed.
+// The caller must hold the game's lock.
+func (s *SocketServer) askSuddenDeath(game *Game, contenders []string) error {
+	question := *game.SuddenDeath
+	question.Contenders = contenders
+	err := s.Publish(game, ServerMessage{
+		Type: "notify_all",
+		Data: fmt.Sprintf("Sudden death!  %s are tied for first, and the closest guess wins.", strings.Join(contenders, ", ")),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("sudden death between %s\n", strings.Join(contenders, ", "))
+	return s.Ask(game, question)
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompareScores(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name        string
+		tieBreakers []string
+		a, b        PlayerScore
+		want        int
+	}{
+		{
+			name: "a higher score",
+			a:    PlayerScore{Score: 30},
+			b:    PlayerScore{Score: 20, Correct: 5},
+			want: -1,
+		},
+		{
+			name: "no tie-breakers",
+			a:    PlayerScore{Score: 20, Correct: 1},
+			b:    PlayerScore{Score: 20, Correct: 2},
+			want: 0,
+		},
+		{
+			name:        "more correct answers",
+			tieBreakers: []string{TieCorrect},
+			a:           PlayerScore{Score: 20, Correct: 1},
+			b:           PlayerScore{Score: 20, Correct: 2},
+			want:        1,
+		},
+		{
+			name:        "less time to answer",
+			tieBreakers: []string{TieTime},
+			a:           PlayerScore{Score: 20, AnswerTime: 4.5},
+			b:           PlayerScore{Score: 20, AnswerTime: 4.7},
+			want:        -1,
+		},
+		{
+			name:        "the score reached first",
+			tieBreakers: []string{TieLast},
+			a:           PlayerScore{Score: 20, LastCorrect: now},
+			b:           PlayerScore{Score: 20, LastCorrect: now.Add(-time.Second)},
+			want:        1,
+		},
+		{
+			name:        "no correct answer never reaches the score",
+			tieBreakers: []string{TieLast},
+			a:           PlayerScore{Score: 20},
+			b:           PlayerScore{Score: 20, LastCorrect: now},
+			want:        1,
+		},
+		{
+			name:        "the next tie-breaker",
+			tieBreakers: []string{TieCorrect, TieTime},
+			a:           PlayerScore{Score: 20, Correct: 2, AnswerTime: 3},
+			b:           PlayerScore{Score: 20, Correct: 2, AnswerTime: 9},
+			want:        -1,
+		},
+		{
+			name:        "still tied",
+			tieBreakers: []string{TieCorrect, TieTime, TieLast},
+			a:           PlayerScore{Score: 20, Correct: 2, AnswerTime: 3, LastCorrect: now},
+			b:           PlayerScore{Score: 20, Correct: 2, AnswerTime: 3, LastCorrect: now},
+			want:        0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &Game{TieBreakers: tt.tieBreakers}
+			got := g.compareScores(&tt.a, &tt.b)
+			if got > 0 {
+				got = 1
+			} else if got < 0 {
+				got = -1
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTieBreakers(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+		err  bool
+	}{
+		{s: "", want: []string{}},
+		{s: "correct", want: []string{TieCorrect}},
+		{s: "last, time,correct", want: []string{TieLast, TieTime, TieCorrect}},
+		{s: "correct,fastest", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTieBreakers(tt.s)
+		if (err != nil) != tt.err {
+			t.Errorf("%q has the error %v, want an error = %v", tt.s, err, tt.err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q is %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+// Only the players that are still tied for first when the game is
+// finished play the sudden-death question, and the closest guess
+// wins it.
+func TestSuddenDeath(t *testing.T) {
+	tests := []struct {
+		name        string
+		tieBreakers []string
+		contenders  []string
+		want        AuditScores
+	}{
+		{
+			name:       "the tied leaders",
+			contenders: []string{"alice", "bob"},
+			want:       AuditScores{Players: map[string]int{"alice": 40, "bob": 20, "carl": 5}},
+		},
+		{
+			name:        "no tie after the tie-breakers",
+			tieBreakers: []string{TieCorrect},
+			want:        AuditScores{Players: map[string]int{"alice": 20, "bob": 20, "carl": 5}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, g := newTestGame(t, func(g *Game) {
+				q, err := ParseSuddenDeath("In what year was Woodstock?|20|number:1969")
+				mustDo(t, err)
+				g.SuddenDeath = q
+				g.TieBreakers = tt.tieBreakers
+			})
+			join(t, g, "alice", "bob", "carl")
+			mustDo(t, s.SetState(g, StateRunning))
+			ask(t, s, g, "What is the capital of France?|20|Paris")
+			guess(t, s, g, "alice", "Paris")
+			guess(t, s, g, "bob", "Lyon")
+			guess(t, s, g, "carl", "Nice")
+			addPoints(t, s, g, "bob", 20)
+			addPoints(t, s, g, "carl", 5)
+			mustDo(t, s.SetState(g, StateFinished))
+
+			if tt.contenders == nil {
+				if g.State != StateFinished || g.IsOpen() {
+					t.Fatalf("the game is %q with a question open = %v", g.State, g.IsOpen())
+				}
+			} else {
+				if contenders := g.CurrentQuestion.Contenders; !reflect.DeepEqual(contenders, tt.contenders) {
+					t.Fatalf("got contenders %v, want %v", contenders, tt.contenders)
+				}
+				if _, err := s.Guess(g, player(t, g, "carl"), float64(1969)); err == nil {
+					t.Error("`carl` isn't tied for first, but answered the sudden-death question")
+				}
+				guess(t, s, g, "alice", float64(1970))
+				guess(t, s, g, "bob", float64(1975))
+				if g.State != StateFinished {
+					t.Errorf("the game is %q after the sudden-death question, want %q", g.State, StateFinished)
+				}
+			}
+			checkScores(t, g, tt.want)
+			checkReplay(t, g)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// The host's score changes (adjustments, resets and restores) can be
+// undone, most recent first, and every score can be restored to what
+// it was before a question was asked. Both are worked out by replaying
+// the game's journal, and only the scores change: the questions, the
+// rounds' subtotals and the history are kept.
+
+// Undoes earlier score changes by taking away the points that they
+// added, and adding back the points that they took away. Points that
+// were earned since then are kept.
+type ScoresUndone struct {
+	// The events that were undone, most recent first.
+	Undone  []int          `json:"undone"`
+	Players map[string]int `json:"players"`
+	Teams   map[string]int `json:"teams,omitempty"`
+}
+
+// Sets every score to what it was before a question was asked. A
+// player or team that didn't have a score then has none now.
+type ScoresRestored struct {
+	Question int            `json:"question"`
+	Players  map[string]int `json:"players"`
+	Teams    map[string]int `json:"teams,omitempty"`
+}
+
+// The scores after the game's first `n` events.
+// The caller must hold the game's lock.
+func (g *Game) scoresAt(n int) (AuditScores, error) {
+	replayed, err := ReplayGame(g.Events[:n])
+	if err != nil {
+		return AuditScores{}, err
+	}
+	return replayed.auditScores(), nil
+}
+
+// The host's score changes that haven't been undone, most recent first.
+// An undo can't itself be undone.
+func (g *Game) undoable() []Event {
+	undone := make(map[int]bool)
+	changes := make([]Event, 0)
+	// An undo always comes after the changes that it undid.
+	for i := len(g.Events) - 1; i >= 0; i-- {
+		e := g.Events[i]
+		switch e.Type {
+		case EventScoresUndone:
+			var d ScoresUndone
+			if err := json.Unmarshal(e.Data, &d); err == nil {
+				for _, seq := range d.Undone {
+					undone[seq] = true
+				}
+			}
+		case EventScoreAdjusted, EventScoresReset, EventScoresRestored:
+			if !undone[e.Seq] {
+				changes = append(changes, e)
+			}
+		}
+	}
+	return changes
+}
+
+// Undoes the host's last `n` score changes and returns the events
+// that were undone.
+// The caller must hold the game's lock.
+func (g *Game) UndoScores(n int) ([]int, error) {
+	changes := g.undoable()
+	if len(changes) == 0 {
+		return nil, errors.New("There are no score changes to undo")
+	}
+	if n > len(changes) {
+		return nil, fmt.Errorf("There are only %d score changes to undo", len(changes))
+	}
+	undo := ScoresUndone{Players: make(map[string]int)}
+	if g.TeamPolicy != "" {
+		undo.Teams = make(map[string]int)
+	}
+	for _, e := range changes[:n] {
+		before, err := g.scoresAt(e.Seq - 1)
+		if err != nil {
+			return nil, err
+		}
+		after, err := g.scoresAt(e.Seq)
+		if err != nil {
+			return nil, err
+		}
+		for name, score := range after.Players {
+			if points := before.Players[name] - score; points != 0 {
+				undo.Players[name] += points
+			}
+		}
+		for name, score := range after.Teams {
+			if points := before.Teams[name] - score; points != 0 {
+				undo.Teams[name] += points
+			}
+		}
+		undo.Undone = append(undo.Undone, e.Seq)
+	}
+	if err := g.emit(EventScoresUndone, undo); err != nil {
+		return nil, err
+	}
+	return undo.Undone, nil
+}
+
+func (g *Game) undoScores(d *ScoresUndone) {
+	for name, points := range d.Players {
+		if player, _ := g.HasPlayer(name); player != nil {
+			player.Score += points
+		}
+	}
+	for name, points := range d.Teams {
+		if team, err := g.GetTeam(name); err == nil {
+			team.Score += points
+		}
+	}
+}
+
+// Restores every score to what it was before question `number` was
+// asked.
+// The caller must hold the game's lock.
+func (g *Game) RestoreScores(number int) error {
+	for _, e := range g.Events {
+		if e.Type != EventQuestionAsked {
+			continue
+		}
+		var question AskedQuestion
+		if err := json.Unmarshal(e.Data, &question); err != nil {
+			return err
+		}
+		if question.Number != number {
+			continue
+		}
+		scores, err := g.scoresAt(e.Seq - 1)
+		if err != nil {
+			return err
+		}
+		return g.emit(EventScoresRestored, ScoresRestored{
+			Question: number,
+			Players:  scores.Players,
+			Teams:    scores.Teams,
+		})
+	}
+	return fmt.Errorf("Question %d hasn't been asked", number)
+}
+
+func (g *Game) restoreScores(d *ScoresRestored) {
+	for _, pool := range []GamePlayers{g.Players, g.Benched} {
+		for _, player := range pool {
+			player.Score = d.Players[player.Name]
+		}
+	}
+	if d.Teams != nil {
+		for _, team := range g.Teams {
+			team.Score = d.Teams[team.Name]
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func joinTeam(t *testing.T, g *Game, team string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		_, err := g.Join(name, name, "https://127.0.0.1:3000", team)
+		mustDo(t, err)
+	}
+}
+
+func addPoints(t *testing.T, s *SocketServer, g *Game, name string, points int) {
+	t.Helper()
+	_, err := s.AddPlayerPoints(g, host, name, points)
+	mustDo(t, err)
+}
+
+func addTeamPoints(t *testing.T, s *SocketServer, g *Game, name string, points int) {
+	t.Helper()
+	_, err := s.AddTeamPoints(g, host, name, points)
+	mustDo(t, err)
+}
+
+func checkScores(t *testing.T, g *Game, want AuditScores) {
+	t.Helper()
+	if got := g.auditScores(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got scores %+v, want %+v", got, want)
+	}
+}
+
+func TestUndoScores(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(*Game)
+		changes func(t *testing.T, s *SocketServer, g *Game)
+		undos   []int
+		want    AuditScores
+		undone  int
+		err     bool
+	}{
+		{
+			name: "the last adjustment",
+			changes: func(t *testing.T, s *SocketServer, g *Game) {
+				addPoints(t, s, g, "alice", 50)
+				addPoints(t, s, g, "bob", 30)
+				addPoints(t, s, g, "alice", -10)
+			},
+			undos:  []int{1},
+			want:   AuditScores{Players: map[string]int{"alice": 50, "bob": 30}},
+			undone: 1,
+		},
+		{
+			name: "the last three adjustments",
+			changes: func(t *testing.T, s *SocketServer, g *Game) {
+				addPoints(t, s, g, "alice", 50)
+				addPoints(t, s, g, "bob", 30)
+				addPoints(t, s, g, "alice", -10)
+			},
+			undos:  []int{3},
+			want:   AuditScores{Players: map[string]int{"alice": 0, "bob": 0}},
+			undone: 3,
+		},
+		{
+			name: "the points earned since are kept",
+			changes: func(t *testing.T, s *SocketServer, g *Game) {
+				addPoints(t, s, g, "alice", 50)
+				ask(t, s, g, "In what year was Woodstock?|20|number:1969")
+				guess(t, s, g, "alice", float64(1969))
+				guess(t, s, g, "bob", float64(1969))
+				addPoints(t, s, g, "bob", -5)
+			},
+			undos:  []int{2},
+			want:   AuditScores{Players: map[string]int{"alice": 20, "bob": 20}},
+			undone: 2,
+		},
+		{
+			name: "a reset",
+			changes: func(t *testing.T, s *SocketServer, g *Game) {
+				addPoints(t, s, g, "alice", 50)
+				addPoints(t, s, g, "bob", 30)
+				mustDo(t, s.ResetScores(g, host))
+			},
+			undos:  []int{1},
+			want:   AuditScores{Players: map[string]int{"alice": 50, "bob": 30}},
+			undone: 1,
+		},
+		{
+			name: "a reset and the adjustment after it",
+			changes: func(t *testing.T, s *SocketServer, g *Game) {
+				addPoints(t, s, g, "alice", 50)
+				mustDo(t, s.ResetScores(g, host))
+				addPoints(t, s, g, "bob", 5)
+			},
+			undos:  []int{2},
+			want:   AuditScores{Players: map[string]int{"alice": 50, "bob": 0}},
+			undone: 2,
+		},
+		{
+			name: "undone changes aren't undone again",
+			changes: func(t *testing.T, s *SocketServer, g *Game) {
+				addPoints(t, s, g, "alice", 50)
+				addPoints(t, s, g, "bob", 30)
+				addPoints(t, s, g, "alice", 10)
+			},
+			undos:  []int{1, 1},
+			want:   AuditScores{Players: map[string]int{"alice": 50, "bob": 0}},
+			undone: 1,
+		},
+		{
+			name: "a benched player's score",
+			changes: func(t *testing.T, s *SocketServer, g *Game) {
+				addPoints(t, s, g, "bob", 30)
+				mustDo(t, g.Bench(player(t, g, "bob"), "left"))
+			},
+			undos:  []int{1},
+			want:   AuditScores{Players: map[string]int{"alice": 0, "bob": 0}},
+			undone: 1,
+		},
+		{
+			name:  "team scores",
+			setup: withTeams(TeamCaptain),
+			changes: func(t *testing.T, s *SocketServer, g *Game) {
+				addTeamPoints(t, s, g, "Red", 15)
+				ask(t, s, g, "In what year was Woodstock?|20|number:1969")
+				guess(t, s, g, "alice", float64(1969))
+				guess(t, s, g, "bob", float64(1970))
+				addPoints(t, s, g, "bob", 10)
+				addTeamPoints(t, s, g, "Blue", 5)
+			},
+			undos: []int{3},
+			want: AuditScores{
+				Players: map[string]int{"alice": 20, "bob": 0},
+				Teams:   map[string]int{"Red": 20, "Blue": 0},
+			},
+			undone: 3,
+		},
+		{
+			name: "more than there are",
+			changes: func(t *testing.T, s *SocketServer, g *Game) {
+				addPoints(t, s, g, "alice", 50)
+			},
+			undos: []int{2},
+			err:   true,
+		},
+		{
+			name:    "nothing to undo",
+			changes: func(t *testing.T, s *SocketServer, g *Game) {},
+			undos:   []int{1},
+			err:     true,
+		},
+		{
+			name: "an undo can't be undone",
+			changes: func(t *testing.T, s *SocketServer, g *Game) {
+				addPoints(t, s, g, "alice", 50)
+			},
+			undos: []int{1, 1},
+			err:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, g := newTestGame(t, tt.setup)
+			if tt.setup != nil {
+				joinTeam(t, g, "Red", "alice")
+				joinTeam(t, g, "Blue", "bob")
+			} else {
+				join(t, g, "alice", "bob")
+			}
+			mustDo(t, s.SetState(g, StateRunning))
+			tt.changes(t, s, g)
+			var undone []int
+			var err error
+			for _, n := range tt.undos {
+				if undone, err = s.UndoScores(g, host, n); err != nil {
+					break
+				}
+			}
+			if tt.err {
+				if err == nil {
+					t.Fatalf("undid %v, want an error", undone)
+				}
+				if status := statusOf(err); status != http.StatusConflict {
+					t.Errorf("got status %d, want %d", status, http.StatusConflict)
+				}
+				return
+			}
+			mustDo(t, err)
+			if len(undone) != tt.undone {
+				t.Errorf("undid %v, want %d changes", undone, tt.undone)
+			}
+			checkScores(t, g, tt.want)
+			// The undo is journaled, so it's the same when replayed.
+			checkScores(t, checkReplay(t, g), tt.want)
+		})
+	}
+}
+
+// Plays four questions in teams, with a reset after the second and
+// an adjustment after the third:
+//
+//	question   1    2    -    3    -    4
+//	alice     10   20   reset      -   40
+//	bob       10              30
+//	carl                           5
+func playRestoreGame(t *testing.T) (*SocketServer, *Game) {
+	s, g := newTestGame(t, withTeams(TeamCaptain))
+	joinTeam(t, g, "Red", "alice", "carl")
+	joinTeam(t, g, "Blue", "bob")
+	mustDo(t, s.SetState(g, StateRunning))
+	// Only the captains' (alice's and bob's) guesses count for the teams.
+	ask(t, s, g, "In what year was Woodstock?|10|number:1969")
+	guess(t, s, g, "alice", float64(1969))
+	guess(t, s, g, "bob", float64(1969))
+	guess(t, s, g, "carl", float64(1970))
+	ask(t, s, g, "What's the capital of France?|20|Paris")
+	guess(t, s, g, "alice", "Paris")
+	guess(t, s, g, "bob", "Lyon")
+	guess(t, s, g, "carl", "Nice")
+	mustDo(t, s.ResetScores(g, host))
+	ask(t, s, g, "What's the capital of Italy?|30|Rome")
+	guess(t, s, g, "alice", "Milan")
+	guess(t, s, g, "bob", "Rome")
+	guess(t, s, g, "carl", "Turin")
+	addPoints(t, s, g, "carl", 5)
+	ask(t, s, g, "What's the capital of Spain?|40|Madrid")
+	guess(t, s, g, "alice", "Madrid")
+	guess(t, s, g, "bob", "Seville")
+	guess(t, s, g, "carl", "Bilbao")
+	return s, g
+}
+
+func TestRestoreScores(t *testing.T) {
+	final := AuditScores{
+		Players: map[string]int{"alice": 40, "bob": 30, "carl": 5},
+		Teams:   map[string]int{"Red": 40, "Blue": 30},
+	}
+	tests := []struct {
+		question int
+		want     AuditScores
+	}{
+		{1, AuditScores{
+			Players: map[string]int{"alice": 0, "bob": 0, "carl": 0},
+			Teams:   map[string]int{"Red": 0, "Blue": 0},
+		}},
+		{2, AuditScores{
+			Players: map[string]int{"alice": 10, "bob": 10, "carl": 0},
+			Teams:   map[string]int{"Red": 10, "Blue": 10},
+		}},
+		// The reset was before the third question.
+		{3, AuditScores{
+			Players: map[string]int{"alice": 0, "bob": 0, "carl": 0},
+			Teams:   map[string]int{"Red": 0, "Blue": 0},
+		}},
+		{4, AuditScores{
+			Players: map[string]int{"alice": 0, "bob": 30, "carl": 5},
+			Teams:   map[string]int{"Red": 0, "Blue": 30},
+		}},
+	}
+	for _, tt := range tests {
+		s, g := playRestoreGame(t)
+		checkScores(t, g, final)
+		mustDo(t, s.RestoreScores(g, host, tt.question))
+		checkScores(t, g, tt.want)
+		checkScores(t, checkReplay(t, g), tt.want)
+
+		// A restore can be undone.
+		_, err := s.UndoScores(g, host, 1)
+		mustDo(t, err)
+		checkScores(t, g, final)
+		checkScores(t, checkReplay(t, g), final)
+	}
+}
+
+func TestRestoreScoresErrors(t *testing.T) {
+	s, g := playRestoreGame(t)
+	if err := s.RestoreScores(g, host, 5); statusOf(err) != http.StatusNotFound {
+		t.Errorf("restoring to before a question that wasn't asked got %v, want a 404", err)
+	}
+	mustDo(t, s.SetState(g, StateFinished))
+	if err := s.RestoreScores(g, host, 1); statusOf(err) != http.StatusConflict {
+		t.Errorf("restoring a finished game's scores got %v, want a 409", err)
+	}
+	if _, err := s.UndoScores(g, host, 1); statusOf(err) != http.StatusConflict {
+		t.Errorf("undoing a finished game's scores got %v, want a 409", err)
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The phases of a wager question. The category is shown first,
+// and the players place their wagers before the question is shown.
+// Every other question skips straight to the open phase.
+const (
+	PhaseWager = "wager"
+	PhaseOpen  = "open"
+)
+
+// What is published when a wager question is asked, which is
+// everything but the question itself.
+type Wagering struct {
+	Number   int    `json:"number"`
+	Round    int    `json:"round,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+// Records a player's wager for the current question, which can be
+// changed until the question is shown. A player can wager up to
+// their whole score (but never less than zero).
+func (g *Game) PlaceWager(player *Player, amount int) error {
+	q := &g.CurrentQuestion
+	if err := g.CheckRunning(); err != nil {
+		return err
+	}
+	if !g.IsOpen() || !q.Wager {
+		return errors.New("There is nothing to wager on")
+	}
+	if q.Phase != PhaseWager {
+		return errors.New("It's too late to wager")
+	}
+	max := player.Score
+	if max < 0 {
+		max = 0
+	}
+	if amount < 0 || amount > max {
+		return fmt.Errorf("Your wager must be between 0 and %d", max)
+	}
+	return g.emit(EventWagerPlaced, WagerPlaced{
+		Name:   player.Name,
+		Amount: amount,
+	})
+}
+
+// The question of a wager question should be shown once every
+// active player has placed their wager.
+func (g *Game) ReadyToShow() bool {
+	if !g.IsOpen() || g.CurrentQuestion.Phase != PhaseWager || len(g.Players) == 0 {
+		return false
+	}
+	for _, player := range g.Players {
+		if _, ok := g.CurrentQuestion.Wagers[player.Name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// A correct answer wins the player's wager, and anything else
+// loses it. A player that didn't wager has nothing to win or lose.
+func (q *CurrentQuestion) wagerPoints(name string, correct bool) int {
+	if correct {
+		return q.Wagers[name]
+	}
+	return -q.Wagers[name]
+}
@@ -0,0 +1,50 @@
+package server
+
+import "testing"
+
+// The question of a wager question is shown once everyone that's
+// still in the game has wagered, however the last of the others left.
+func TestShowWagerQuestion(t *testing.T) {
+	tests := []struct {
+		name  string
+		leave func(t *testing.T, s *SocketServer, g *Game)
+		shown bool
+	}{
+		{
+			name:  "no one left",
+			leave: func(t *testing.T, s *SocketServer, g *Game) {},
+		},
+		{
+			name: "the last to wager is kicked",
+			leave: func(t *testing.T, s *SocketServer, g *Game) {
+				mustDo(t, s.KickPlayers(g, host, GamePlayers{player(t, g, "carl")}))
+			},
+			shown: true,
+		},
+		{
+			name: "the last to wager leaves",
+			leave: func(t *testing.T, s *SocketServer, g *Game) {
+				carl := player(t, g, "carl")
+				mustDo(t, g.Bench(carl, "left"))
+				s.benched(g, carl)
+			},
+			shown: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, g := newTestGame(t, nil)
+			join(t, g, "alice", "bob", "carl")
+			mustDo(t, s.SetState(g, StateRunning))
+			addPoints(t, s, g, "alice", 100)
+			ask(t, s, g, "In what year was Woodstock?|wager:History|number:1969")
+			mustDo(t, g.PlaceWager(player(t, g, "alice"), 60))
+			mustDo(t, g.PlaceWager(player(t, g, "bob"), 0))
+			tt.leave(t, s, g)
+			if shown := g.CurrentQuestion.Phase == PhaseOpen; shown != tt.shown {
+				t.Fatalf("the question is shown = %v, want %v", shown, tt.shown)
+			}
+			checkReplay(t, g)
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/btoll/trivial/src/middleware"
+	"golang.org/x/net/websocket"
+)
+
+// The host's endpoints that give away the answers or the game's
+// history take the game's host key in this header, along with the
+// API key, since the players log in with the API key.
+const HostKeyHeader = "X-Trivia-Hostkey"
+
+// A guess as the host sees it, which is published to the watchers as
+// a `guess` event as soon as it's made. Its points are only awarded
+// once the question is closed.
+type Guess struct {
+	Number  int    `json:"number"`
+	Name    string `json:"name"`
+	Guess   string `json:"guess"`
+	Correct bool   `json:"correct"`
+	Points  int    `json:"points"`
+}
+
+// Everything a watcher needs to catch up when it connects, which is
+// what a display gets along with the guesses to the current question.
+type WatchSnapshot struct {
+	*DisplaySnapshot
+	Guesses []Guess `json:"guesses"`
+}
+
+func newGuess(game *Game, name, guess string, response *Response) Guess {
+	return Guess{
+		Number:  game.CurrentQuestion.Number,
+		Name:    name,
+		Guess:   guess,
+		Correct: response.Correct,
+		Points:  response.Points,
+	}
+}
+
+// The guesses to the current question in the order they were made.
+// The caller must hold the game's lock.
+func (g *Game) GetGuesses() []Guess {
+	guesses := make([]Guess, 0, len(g.CurrentQuestion.Responses))
+	for name, response := range g.CurrentQuestion.Responses {
+		guesses = append(guesses, newGuess(g, name, g.CurrentQuestion.FormatGuess(response.Guess), response))
+	}
+	sort.SliceStable(guesses, func(i, j int) bool {
+		a, b := g.CurrentQuestion.Responses[guesses[i].Name], g.CurrentQuestion.Responses[guesses[j].Name]
+		return a.Received.Before(b.Received)
+	})
+	return guesses
+}
+
+// Each game has its own host key, which is only given to the host, so
+// that a player can't watch the guesses come in.
+func (s *SocketServer) GetGameByHostKey(key string) (*Game, error) {
+	if key == "" {
+		return nil, errors.New("host key is an empty string")
+	}
+	for _, game := range s.Games {
+		if subtle.ConstantTimeCompare([]byte(game.HostKey), []byte(key)) == 1 {
+			return game, nil
+		}
+	}
+	return nil, errors.New("Bad host key")
+}
+
+// The game of the API key, as long as the request also has its host
+// key in the [HostKeyHeader]. Otherwise, the error has been written.
+func (s *SocketServer) hostGame(w http.ResponseWriter, r *http.Request) (*Game, bool) {
+	apiKey := r.Context().Value("apiKey").(*middleware.APIKey)
+	game, err := s.GetGame(apiKey.Key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if err := game.checkHostKey(r); err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return nil, false
+	}
+	return game, true
+}
+
+func (g *Game) checkHostKey(r *http.Request) error {
+	key := r.Header.Get(HostKeyHeader)
+	if key == "" || subtle.ConstantTimeCompare([]byte(g.HostKey), []byte(key)) != 1 {
+		return statusErrorf(http.StatusForbidden, "Bad host key")
+	}
+	return nil
+}
+
+// An API endpoint that, like [SocketServer.hostGame], also needs the
+// game's host key.
+func hostOnly(handler apiHandler) apiHandler {
+	return func(game *Game, r *apiRequest) (int, any, error) {
+		if err := game.checkHostKey(r.Request); err != nil {
+			return 0, nil, err
+		}
+		return handler(game, r)
+	}
+}
+
+// The caller must hold the game's lock.
+func (g *Game) AddWatcher(socket *websocket.Conn) {
+	g.Watchers = append(g.Watchers, socket)
+}
+
+// Returns false if the socket isn't one of the game's watchers.
+// The caller must hold the game's lock.
+func (g *Game) RemoveWatcher(socket *websocket.Conn) bool {
+	for i, watcher := range g.Watchers {
+		if watcher == socket {
+			g.Watchers = append(g.Watchers[:i], g.Watchers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Notifies only the host's watchers of an event that would give
+// away the answers.
+func (s *SocketServer) PublishWatchers(game *Game, msg ServerMessage) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	broadcast(game.Watchers, msg.Type, b)
+	return nil
+}
+
+// The host watches a game over the websocket with its host key. A
+// watcher gets every event that a display does and every guess as
+// it's made. See [SocketServer.PublishWatchers].
+func (s *SocketServer) connectWatcher(socket *websocket.Conn, key string) {
+	game, err := s.GetGameByHostKey(key)
+	if err != nil {
+		err = s.Message(socket, ServerMessage{
+			Type: "error",
+			Data: err.Error(),
+		})
+		if err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+	game.mu.Lock()
+	defer game.mu.Unlock()
+	snapshot, err := game.Snapshot()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	game.AddWatcher(socket)
+	err = s.Message(socket, ServerMessage{
+		Type: "watch",
+		Data: WatchSnapshot{
+			DisplaySnapshot: snapshot,
+			Guesses:         game.GetGuesses(),
+		},
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Printf("the host is watching game `%s`\n", game.Name)
+}
+
+// Returns false if the socket isn't a watcher of any game.
+func (s *SocketServer) disconnectWatcher(socket *websocket.Conn) bool {
+	for _, game := range s.Games {
+		game.mu.Lock()
+		removed := game.RemoveWatcher(socket)
+		game.mu.Unlock()
+		if removed {
+			return true
+		}
+	}
+	return false
+}
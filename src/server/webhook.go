@@ -0,0 +1,71 @@
+package server
+
+import (
+	"github.com/btoll/trivial/src/webhook"
+)
+
+// The data of a `player.joined` or `player.left` event. A player
+// leaves when their browser disconnects or when they're kicked.
+type PlayerEvent struct {
+	Name    string `json:"name"`
+	Team    string `json:"team,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Players int    `json:"players"`
+}
+
+// The data of a `question.closed` event, which is the `reveal` event
+// along with the question's statistics.
+type ClosedQuestion struct {
+	*Reveal
+	Stats *QuestionStats `json:"stats"`
+}
+
+// The data of a `game.finished` event.
+type FinishedGame struct {
+	Winners        []string       `json:"winners"`
+	Questions      int            `json:"questions"`
+	Scoreboard     Scoreboard     `json:"scoreboard"`
+	TeamScoreboard TeamScoreboard `json:"teamScoreboard,omitempty"`
+}
+
+// Sends an event to the webhooks, if there are any.
+// The caller must hold the game's lock.
+func (s *SocketServer) fire(game *Game, event string, data any) {
+	if s.Webhooks != nil {
+		s.Webhooks.Send(game.Name, event, data)
+	}
+}
+
+func (s *SocketServer) firePlayer(game *Game, event string, player *Player, reason string) {
+	s.fire(game, event, PlayerEvent{
+		Name:    player.Name,
+		Team:    player.Team,
+		Reason:  reason,
+		Players: len(game.Players),
+	})
+}
+
+// The question as the players see it, without its answer, since a
+// chat channel may well be read by the players.
+// The caller must hold the game's lock.
+func (s *SocketServer) fireQuestion(game *Game) {
+	s.fire(game, webhook.QuestionPublished, game.CurrentQuestion.shown())
+}
+
+// The caller must hold the game's lock.
+func (s *SocketServer) fireFinished(game *Game) {
+	finished := FinishedGame{
+		Winners:    make([]string, 0),
+		Questions:  len(game.Questions),
+		Scoreboard: game.GetScoreboard(),
+	}
+	for _, score := range finished.Scoreboard {
+		if score.Rank == 1 {
+			finished.Winners = append(finished.Winners, score.Name)
+		}
+	}
+	if game.TeamPolicy != "" {
+		finished.TeamScoreboard = game.GetTeamScoreboard()
+	}
+	s.fire(game, webhook.GameFinished, finished)
+}
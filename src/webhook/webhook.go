@@ -0,0 +1,266 @@
+// Package webhook sends the game's events to outgoing webhooks, like a
+// team chat or a stats pipeline. Each hook is configured with the URL
+// that the events are POSTed to, the events it wants and the secret
+// that its deliveries are signed with. For example:
+//
+//	[
+//	    {
+//	        "url": "https://chat.example.com/hooks/trivia",
+//	        "events": ["question.closed", "game.finished"],
+//	        "secret": "s3cr3t"
+//	    }
+//	]
+//
+// A delivery that fails is retried with exponential backoff. The
+// receiver can check that a delivery came from the game with [Verify].
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The events that a hook can ask for.
+const (
+	PlayerJoined      = "player.joined"
+	PlayerLeft        = "player.left"
+	QuestionPublished = "question.published"
+	QuestionClosed    = "question.closed"
+	GameFinished      = "game.finished"
+)
+
+var Events = []string{PlayerJoined, PlayerLeft, QuestionPublished, QuestionClosed, GameFinished}
+
+// The headers of every delivery. The signature is the hex HMAC-SHA256
+// of the timestamp, a period and the body, keyed by the hook's secret.
+const (
+	HeaderEvent     = "X-Trivia-Event"
+	HeaderDelivery  = "X-Trivia-Delivery"
+	HeaderTimestamp = "X-Trivia-Timestamp"
+	HeaderSignature = "X-Trivia-Signature"
+)
+
+const (
+	DefaultAttempts = 5
+	DefaultBackoff  = time.Second
+	// The longest that a delivery waits before it's retried.
+	maxBackoff = time.Minute
+)
+
+type Hook struct {
+	URL string `json:"url"`
+	// The hook gets every event when it doesn't name any.
+	Events []string `json:"events,omitempty"`
+	// The deliveries aren't signed without a secret.
+	Secret string `json:"secret,omitempty"`
+	// How many times a delivery is tried, which is [DefaultAttempts]
+	// when it's zero.
+	Attempts int `json:"attempts,omitempty"`
+}
+
+func (h Hook) wants(event string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == event || e == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (h Hook) attempts() int {
+	if h.Attempts > 0 {
+		return h.Attempts
+	}
+	return DefaultAttempts
+}
+
+// What's POSTed to the hook as JSON.
+type Event struct {
+	ID   string    `json:"id"`
+	Type string    `json:"type"`
+	Game string    `json:"game"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+// Reads the hooks from a JSON file.
+func Load(path string) ([]Hook, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var hooks []Hook
+	if err := json.Unmarshal(b, &hooks); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for i, hook := range hooks {
+		if hook.URL == "" {
+			return nil, fmt.Errorf("%s: hook %d has no url", path, i+1)
+		}
+		for _, event := range hook.Events {
+			if !known(event) {
+				return nil, fmt.Errorf("%s: hook %d has an unknown event `%s`", path, i+1, event)
+			}
+		}
+	}
+	return hooks, nil
+}
+
+func known(event string) bool {
+	if event == "*" {
+		return true
+	}
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Reports whether a delivery was signed with the secret. The receiver
+// should also check that the timestamp is recent, so that a delivery
+// can't be replayed.
+func Verify(secret string, r *http.Request, body []byte) bool {
+	expected := Sign(secret, r.Header.Get(HeaderTimestamp), body)
+	return hmac.Equal([]byte(expected), []byte(r.Header.Get(HeaderSignature)))
+}
+
+// Delivers the events to the hooks in the background. The deliveries
+// of an event to each hook are independent, and an event may arrive
+// before one that was sent earlier but is being retried.
+type Dispatcher struct {
+	Hooks      []Hook
+	HTTPClient *http.Client
+	// How long the first retry waits, which doubles after each try.
+	Backoff time.Duration
+	wg      sync.WaitGroup
+}
+
+// Uses a client with a 10 second timeout when `httpClient` is nil.
+func New(hooks []Hook, httpClient *http.Client) *Dispatcher {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Dispatcher{
+		Hooks:      hooks,
+		HTTPClient: httpClient,
+		Backoff:    DefaultBackoff,
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Sends an event to every hook that wants it without waiting for the
+// deliveries.
+func (d *Dispatcher) Send(game, event string, data any) {
+	e := Event{
+		ID:   newID(),
+		Type: event,
+		Game: game,
+		Time: time.Now().UTC(),
+		Data: data,
+	}
+	// The data is marshaled now, since it may change once the
+	// caller lets go of the game's lock.
+	body, err := json.Marshal(e)
+	if err != nil {
+		fmt.Println("webhook error:", err)
+		return
+	}
+	for _, hook := range d.Hooks {
+		if !hook.wants(event) {
+			continue
+		}
+		d.wg.Add(1)
+		go func(hook Hook) {
+			defer d.wg.Done()
+			if err := d.deliver(hook, e, body); err != nil {
+				fmt.Printf("webhook `%s` failed to deliver %s event %s: %v\n", hook.URL, event, e.ID, err)
+			}
+		}(hook)
+	}
+}
+
+// Waits for the deliveries (and their retries) that are in flight.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// A delivery is retried when it can't be sent, or when the hook
+// answers 429 Too Many Requests or a 5xx status. Any other status
+// of 400 or more is given up on.
+func (d *Dispatcher) deliver(hook Hook, e Event, body []byte) error {
+	backoff := d.Backoff
+	var err error
+	for attempt := 1; attempt <= hook.attempts(); attempt++ {
+		if attempt > 1 {
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+		var retry bool
+		retry, err = d.post(hook, e, body)
+		if err == nil || !retry {
+			return err
+		}
+	}
+	return fmt.Errorf("gave up after %d attempts: %v", hook.attempts(), err)
+}
+
+func (d *Dispatcher) post(hook Hook, e Event, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "trivial-webhook")
+	req.Header.Set(HeaderEvent, e.Type)
+	req.Header.Set(HeaderDelivery, e.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if hook.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, body))
+	}
+	res, err := d.HTTPClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+	if res.StatusCode < http.StatusBadRequest {
+		return false, nil
+	}
+	retry := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError
+	return retry, fmt.Errorf("%s", res.Status)
+}
@@ -0,0 +1,286 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A hook's receiver, which answers each delivery with the next of its
+// `statuses` (and then 200 OK), after sleeping for the next of its
+// `delays`.
+type receiver struct {
+	mu         sync.Mutex
+	statuses   []int
+	delays     []time.Duration
+	deliveries []delivery
+}
+
+type delivery struct {
+	header http.Header
+	body   []byte
+	at     time.Time
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	n := len(rc.deliveries)
+	rc.deliveries = append(rc.deliveries, delivery{
+		header: r.Header.Clone(),
+		body:   body,
+		at:     time.Now(),
+	})
+	var delay time.Duration
+	if n < len(rc.delays) {
+		delay = rc.delays[n]
+	}
+	status := http.StatusOK
+	if n < len(rc.statuses) {
+		status = rc.statuses[n]
+	}
+	rc.mu.Unlock()
+	time.Sleep(delay)
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) received() []delivery {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]delivery(nil), rc.deliveries...)
+}
+
+func newReceiver(t *testing.T, rc *receiver) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(rc)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newDispatcher(hooks ...Hook) *Dispatcher {
+	d := New(hooks, nil)
+	d.Backoff = 10 * time.Millisecond
+	return d
+}
+
+func TestSignature(t *testing.T) {
+	rc := &receiver{}
+	server := newReceiver(t, rc)
+	d := newDispatcher(Hook{URL: server.URL, Secret: "s3cr3t"})
+	d.Send("default", QuestionClosed, map[string]int{"number": 3})
+	d.Wait()
+
+	deliveries := rc.received()
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(deliveries))
+	}
+	got := deliveries[0]
+	r := &http.Request{Header: got.header}
+	if !Verify("s3cr3t", r, got.body) {
+		t.Errorf("the signature %q doesn't verify", got.header.Get(HeaderSignature))
+	}
+	if Verify("wrong", r, got.body) {
+		t.Error("the signature verifies with the wrong secret")
+	}
+	if Verify("s3cr3t", r, append(got.body, ' ')) {
+		t.Error("the signature verifies a different body")
+	}
+	want := Sign("s3cr3t", got.header.Get(HeaderTimestamp), got.body)
+	if sig := got.header.Get(HeaderSignature); sig != want || !strings.HasPrefix(sig, "sha256=") {
+		t.Errorf("%s = %q, want %q", HeaderSignature, sig, want)
+	}
+	if event := got.header.Get(HeaderEvent); event != QuestionClosed {
+		t.Errorf("%s = %q, want %q", HeaderEvent, event, QuestionClosed)
+	}
+
+	var e Event
+	if err := json.Unmarshal(got.body, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != QuestionClosed || e.Game != "default" || e.ID != got.header.Get(HeaderDelivery) {
+		t.Errorf("got event %+v with delivery %q", e, got.header.Get(HeaderDelivery))
+	}
+}
+
+func TestUnsigned(t *testing.T) {
+	rc := &receiver{}
+	server := newReceiver(t, rc)
+	d := newDispatcher(Hook{URL: server.URL})
+	d.Send("default", GameFinished, nil)
+	d.Wait()
+
+	deliveries := rc.received()
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(deliveries))
+	}
+	if sig := deliveries[0].header.Get(HeaderSignature); sig != "" {
+		t.Errorf("a hook without a secret got the signature %q", sig)
+	}
+}
+
+func TestWants(t *testing.T) {
+	tests := []struct {
+		events []string
+		event  string
+		want   bool
+	}{
+		{nil, PlayerJoined, true},
+		{nil, GameFinished, true},
+		{[]string{"*"}, QuestionPublished, true},
+		{[]string{QuestionClosed}, QuestionClosed, true},
+		{[]string{QuestionClosed}, QuestionPublished, false},
+		{[]string{PlayerJoined, PlayerLeft}, PlayerLeft, true},
+		{[]string{PlayerJoined, PlayerLeft}, GameFinished, false},
+	}
+	for _, tt := range tests {
+		if got := (Hook{Events: tt.events}).wants(tt.event); got != tt.want {
+			t.Errorf("a hook for %v wants %s = %v, want %v", tt.events, tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestSendFilters(t *testing.T) {
+	closed, finished, all := &receiver{}, &receiver{}, &receiver{}
+	d := newDispatcher(
+		Hook{URL: newReceiver(t, closed).URL, Events: []string{QuestionClosed}},
+		Hook{URL: newReceiver(t, finished).URL, Events: []string{GameFinished}},
+		Hook{URL: newReceiver(t, all).URL},
+	)
+	d.Send("default", QuestionClosed, nil)
+	d.Send("default", PlayerJoined, nil)
+	d.Wait()
+
+	tests := []struct {
+		name string
+		rc   *receiver
+		want []string
+	}{
+		{"question.closed", closed, []string{QuestionClosed}},
+		{"game.finished", finished, nil},
+		{"every event", all, []string{QuestionClosed, PlayerJoined}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, delivery := range tt.rc.received() {
+			got = append(got, delivery.header.Get(HeaderEvent))
+		}
+		// The deliveries of different events can arrive in any order.
+		if len(got) != len(tt.want) {
+			t.Errorf("the hook for %s got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for _, event := range tt.want {
+			if !contains(got, event) {
+				t.Errorf("the hook for %s got %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	}
+}
+
+func contains(events []string, event string) bool {
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+	}{
+		{"500", []int{http.StatusInternalServerError}, 2},
+		{"503 twice", []int{http.StatusServiceUnavailable, http.StatusBadGateway}, 3},
+		{"429", []int{http.StatusTooManyRequests}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &receiver{statuses: tt.statuses}
+			server := newReceiver(t, rc)
+			d := newDispatcher()
+			hook := Hook{URL: server.URL}
+			if err := d.deliver(hook, Event{ID: "1", Type: QuestionClosed}, []byte("{}")); err != nil {
+				t.Fatalf("the delivery failed: %v", err)
+			}
+			deliveries := rc.received()
+			if len(deliveries) != tt.attempts {
+				t.Fatalf("got %d attempts, want %d", len(deliveries), tt.attempts)
+			}
+			// Each retry waits twice as long as the last.
+			backoff := d.Backoff
+			for i := 1; i < len(deliveries); i++ {
+				if waited := deliveries[i].at.Sub(deliveries[i-1].at); waited < backoff {
+					t.Errorf("retry %d waited %v, want at least %v", i, waited, backoff)
+				}
+				backoff *= 2
+			}
+			// A retry is the same delivery.
+			for _, delivery := range deliveries {
+				if id := delivery.header.Get(HeaderDelivery); id != "1" {
+					t.Errorf("%s = %q, want %q", HeaderDelivery, id, "1")
+				}
+			}
+		})
+	}
+}
+
+func TestRetryTimeout(t *testing.T) {
+	rc := &receiver{delays: []time.Duration{200 * time.Millisecond}}
+	server := newReceiver(t, rc)
+	d := New(nil, &http.Client{Timeout: 50 * time.Millisecond})
+	d.Backoff = 10 * time.Millisecond
+	if err := d.deliver(Hook{URL: server.URL}, Event{ID: "1"}, []byte("{}")); err != nil {
+		t.Fatalf("the delivery failed: %v", err)
+	}
+	if n := len(rc.received()); n != 2 {
+		t.Errorf("got %d attempts, want 2", n)
+	}
+}
+
+func TestGiveUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		want     int
+	}{
+		{"attempts", 3, 3},
+		{"default attempts", 0, DefaultAttempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &receiver{statuses: []int{500, 500, 500, 500, 500, 500}}
+			server := newReceiver(t, rc)
+			d := newDispatcher()
+			d.Backoff = time.Millisecond
+			err := d.deliver(Hook{URL: server.URL, Attempts: tt.attempts}, Event{ID: "1"}, []byte("{}"))
+			if err == nil || !strings.Contains(err.Error(), "gave up after") {
+				t.Fatalf("got error %v, want it to give up", err)
+			}
+			if n := len(rc.received()); n != tt.want {
+				t.Errorf("got %d attempts, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoRetry(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound} {
+		rc := &receiver{statuses: []int{status}}
+		server := newReceiver(t, rc)
+		d := newDispatcher()
+		if err := d.deliver(Hook{URL: server.URL}, Event{ID: "1"}, []byte("{}")); err == nil {
+			t.Errorf("a %d didn't fail the delivery", status)
+		}
+		if n := len(rc.received()); n != 1 {
+			t.Errorf("a %d was tried %d times, want 1", status, n)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/btoll/trivial/src/client"
+	"github.com/btoll/trivial/src/server"
+	"golang.org/x/net/websocket"
+)
+
+// What the host sees of the game, which is redrawn after every event.
+type watchView struct {
+	mu             sync.Mutex
+	game           string
+	state          server.GameState
+	players        int
+	question       *server.CurrentQuestion
+	answered       server.Answered
+	guesses        []server.Guess
+	stats          *server.QuestionStats
+	scoreboard     server.Scoreboard
+	teamScoreboard server.TeamScoreboard
+	notices        []string
+}
+
+// Like a [server.ServerMessage], but its data is decoded by its type.
+type watchMessage struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+const watchHelp = "n[ext]  a[sk] <line>  s[how]  r[eveal]  p[ause]  c[ontinue]  f[inish]  k[ick] <name>  m[sg] <name> <message>  q[uit]"
+
+// Watches the game live over the websocket, with every guess as it's
+// made, and takes the host's commands from stdin. For example:
+//
+//	$ trivial watch -deck questions.txt
+func watch(args []string) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	deck := fs.String("deck", "", "File of questions that `n` asks from (defaults to the config)")
+	config, _ := parseHostFlags(fs, args, 0, "")
+	if *deck != "" {
+		config.Deck = *deck
+	}
+	c := config.client()
+	if config.HostKey == "" {
+		log.Fatalln("the host key is required (see `trivial config`)")
+	}
+
+	socket, err := config.dialWatch()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer socket.Close()
+
+	view := &watchView{}
+	go view.commands(config, c, os.Stdin)
+	for {
+		var msg watchMessage
+		if err := websocket.JSON.Receive(socket, &msg); err != nil {
+			if err == io.EOF {
+				log.Fatalln("the server closed the connection")
+			}
+			log.Fatalln(err)
+		}
+		view.mu.Lock()
+		view.update(msg)
+		view.render(os.Stdout)
+		view.mu.Unlock()
+	}
+}
+
+// The websocket is found at the same address as the host server.
+func (c hostConfig) dialWatch() (*websocket.Conn, error) {
+	u, err := url.Parse(c.Host)
+	if err != nil {
+		return nil, err
+	}
+	origin := u.String()
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	}
+	u.Path = "/ws"
+	u.RawQuery = fmt.Sprintf("uuid=host-%d", rand.Int63())
+	wsConfig, err := websocket.NewConfig(u.String(), origin)
+	if err != nil {
+		return nil, err
+	}
+	wsConfig.TlsConfig = c.tlsConfig()
+	socket, err := websocket.DialConfig(wsConfig)
+	if err != nil {
+		return nil, err
+	}
+	err = websocket.JSON.Send(socket, server.ClientMessage{
+		Type:  "watch",
+		Token: c.HostKey,
+	})
+	if err != nil {
+		socket.Close()
+		return nil, err
+	}
+	return socket, nil
+}
+
+// Only the last few notices are shown.
+func (v *watchView) notify(format string, a ...any) {
+	notice := fmt.Sprintf("%s  %s", time.Now().Format("15:04:05"), fmt.Sprintf(format, a...))
+	v.notices = append(v.notices, notice)
+	if len(v.notices) > 5 {
+		v.notices = v.notices[len(v.notices)-5:]
+	}
+}
+
+// The caller must hold the view's lock.
+func (v *watchView) update(msg watchMessage) {
+	var err error
+	switch msg.Type {
+	case "watch":
+		var snapshot server.WatchSnapshot
+		if err = json.Unmarshal(msg.Data, &snapshot); err == nil && snapshot.DisplaySnapshot != nil {
+			v.game = snapshot.Game
+			v.state = snapshot.State
+			v.players = len(snapshot.State.Players)
+			v.answered = snapshot.Answered
+			v.scoreboard = snapshot.Scoreboard
+			v.teamScoreboard = snapshot.TeamScoreboard
+			v.guesses = snapshot.Guesses
+			v.question = nil
+			if snapshot.Question != "" {
+				v.question = &server.CurrentQuestion{}
+				err = json.Unmarshal([]byte(snapshot.Question), v.question)
+			}
+			v.notify("watching game `%s`", v.game)
+		}
+	case "state":
+		if err = json.Unmarshal(msg.Data, &v.state); err == nil {
+			v.players = len(v.state.Players)
+			if len(v.state.Scoreboard) > 0 {
+				v.scoreboard = v.state.Scoreboard
+			}
+		}
+	case "question":
+		// The question is sent as a JSON string.
+		var s string
+		if err = json.Unmarshal(msg.Data, &s); err == nil {
+			v.question = &server.CurrentQuestion{}
+			err = json.Unmarshal([]byte(s), v.question)
+			v.guesses = nil
+			v.stats = nil
+			v.answered = server.Answered{Number: v.question.Number, Players: v.players}
+		}
+	case "wager":
+		var wagering server.Wagering
+		if err = json.Unmarshal(msg.Data, &wagering); err == nil {
+			v.question = nil
+			v.guesses = nil
+			v.stats = nil
+			v.notify("the players are wagering on question %d (`s` to show it)", wagering.Number)
+		}
+	case "guess":
+		var guess server.Guess
+		if err = json.Unmarshal(msg.Data, &guess); err == nil {
+			// A player that can change their answer replaces their guess.
+			for i, g := range v.guesses {
+				if g.Name == guess.Name {
+					v.guesses = append(v.guesses[:i], v.guesses[i+1:]...)
+					break
+				}
+			}
+			v.guesses = append(v.guesses, guess)
+		}
+	case "answered":
+		err = json.Unmarshal(msg.Data, &v.answered)
+	case "reveal":
+		var reveal server.Reveal
+		if err = json.Unmarshal(msg.Data, &reveal); err == nil {
+			v.scoreboard = reveal.Scoreboard
+			v.teamScoreboard = reveal.TeamScoreboard
+			v.notify("revealed question %d, the answer is `%s`", reveal.Number, reveal.Answer)
+		}
+	case "stats":
+		v.stats = &server.QuestionStats{}
+		err = json.Unmarshal(msg.Data, v.stats)
+	case "update_scoreboard":
+		err = json.Unmarshal(msg.Data, &v.scoreboard)
+	case "update_team_scoreboard":
+		err = json.Unmarshal(msg.Data, &v.teamScoreboard)
+	case "player_add", "player_delete":
+		var players server.GamePlayers
+		if err = json.Unmarshal(msg.Data, &players); err == nil {
+			v.players = len(players)
+		}
+	case "intermission":
+		var round server.Round
+		if err = json.Unmarshal(msg.Data, &round); err == nil {
+			v.notify("round %d: %s", round.Number, round.Title)
+		}
+	case "buzzer":
+		var buzzer server.BuzzerState
+		if err = json.Unmarshal(msg.Data, &buzzer); err == nil && buzzer.Holder != "" {
+			v.notify("%s buzzed in", buzzer.Holder)
+		}
+	case "notify_all":
+		var s string
+		if err = json.Unmarshal(msg.Data, &s); err == nil {
+			v.notify("everyone was told: %s", s)
+		}
+	case "error":
+		var s string
+		if err = json.Unmarshal(msg.Data, &s); err == nil {
+			v.notify("error: %s", s)
+		}
+	}
+	if err != nil {
+		v.notify("bad `%s` event: %v", msg.Type, err)
+	}
+}
+
+// The caller must hold the view's lock.
+func (v *watchView) render(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	// Clear the terminal.
+	fmt.Fprint(w, "\x1b[H\x1b[2J")
+	fmt.Fprintf(w, "game `%s`  %s  %d players\n%s\n", v.game, v.state.State, v.players, bound(75))
+
+	if q := v.question; q != nil {
+		fmt.Fprintf(w, "question %d: %s\n", q.Number, q.Question)
+		for i, choice := range q.Choices {
+			fmt.Fprintf(w, "  %c) %s\n", 'a'+i, choice)
+		}
+		fmt.Fprintf(w, "answered %d of %d\n\n", v.answered.Answered, v.answered.Players)
+		for _, guess := range v.guesses {
+			mark := "✗"
+			if guess.Correct {
+				mark = "✓"
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%+d\n", mark, guess.Name, guess.Guess, guess.Points)
+		}
+		if v.stats != nil {
+			fmt.Fprintf(w, "\n%d of %d correct (%.0f%%), the answer is `%s`\n", v.stats.Correct, v.stats.Responses, v.stats.PercentCorrect, v.stats.Answer)
+		}
+		fmt.Fprintln(w, bound(75))
+	}
+
+	if len(v.teamScoreboard) > 0 {
+		for i, team := range v.teamScoreboard {
+			fmt.Fprintf(w, "%d.\t%s\t%d\n", i+1, team.Name, team.Score)
+		}
+	} else {
+		for _, player := range v.scoreboard {
+			fmt.Fprintf(w, "%d.\t%s\t%d\n", player.Rank, player.Name, player.Score)
+		}
+	}
+	fmt.Fprintln(w, bound(75))
+
+	for _, notice := range v.notices {
+		fmt.Fprintln(w, notice)
+	}
+	fmt.Fprintf(w, "%s\n> ", watchHelp)
+	w.Flush()
+}
+
+// Runs the host's commands, whose outcome is shown as a notice.
+func (v *watchView) commands(config hostConfig, c *client.Client, in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "q" || fields[0] == "quit" {
+			os.Exit(0)
+		}
+		notice, err := runCommand(config, c, fields)
+		v.mu.Lock()
+		if err != nil {
+			v.notify("error: %v", err)
+		} else if notice != "" {
+			v.notify("%s", notice)
+		}
+		v.render(os.Stdout)
+		v.mu.Unlock()
+	}
+}
+
+func runCommand(config hostConfig, c *client.Client, fields []string) (string, error) {
+	ctx := context.Background()
+	rest := strings.Join(fields[1:], " ")
+	var err error
+	switch fields[0] {
+	case "n", "next":
+		return askNext(config, 0)
+	case "a", "ask":
+		return askLine(c, rest)
+	case "s", "show":
+		_, err = c.Show(ctx)
+	case "r", "reveal":
+		_, err = c.Reveal(ctx)
+	case "p", "pause":
+		_, err = c.Pause(ctx)
+	case "c", "continue":
+		_, err = c.Resume(ctx)
+	case "f", "finish":
+		_, err = c.Finish(ctx)
+	case "k", "kick":
+		if len(fields) < 2 {
+			return "", fmt.Errorf("usage: kick <name>")
+		}
+		if err = c.Kick(ctx, rest); err == nil {
+			return fmt.Sprintf("kicked `%s`", rest), nil
+		}
+	case "u", "undo":
+		n := 1
+		if len(fields) > 1 {
+			if n, err = strconv.Atoi(fields[1]); err != nil {
+				return "", fmt.Errorf("usage: undo [n]")
+			}
+		}
+		res, err := c.Undo(ctx, n)
+		if err == nil {
+			return fmt.Sprintf("undid %d score changes", len(res.Undone)), nil
+		}
+		return "", err
+	case "m", "msg":
+		if len(fields) < 3 {
+			return "", fmt.Errorf("usage: msg <name> <message>")
+		}
+		if err = c.Message(ctx, fields[1], strings.Join(fields[2:], " ")); err == nil {
+			return fmt.Sprintf("told `%s`", fields[1]), nil
+		}
+	default:
+		return "", fmt.Errorf("unknown command `%s`", fields[0])
+	}
+	return "", err
+}